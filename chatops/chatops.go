@@ -0,0 +1,129 @@
+// Package chatops answers slash commands such as `/slackbot status web-03`.
+// Only the read-only queries it's given can be run; the text of a command
+// is never passed to a shell.
+package chatops
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/slack"
+)
+
+// Query is a built-in query.
+type Query struct {
+	Usage string // arguments, e.g. "[host]"
+	Help  string
+
+	// Run answers the query with its arguments.
+	Run func(args []string) (slack.SlackMessage, error)
+}
+
+// Handler serves the request URL of a slash command. It checks that
+// requests are signed with the app's signing secret and answers with a
+// message only the caller sees. Queries are acknowledged at once and
+// answered through the command's response URL, since Slack gives up on a
+// request after 3 seconds.
+type Handler struct {
+	SigningSecret string
+	Queries       map[string]Query
+	Client        *http.Client     // for response URLs
+	Now           func() time.Time // default time.Now
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now
+	if h.Now != nil {
+		now = h.Now
+	}
+	form, err := slack.ReadRequest(h.SigningSecret, r, now())
+	if err != nil {
+		log.Printf("commands: %v", err)
+		http.Error(w, "invalid request", http.StatusUnauthorized)
+		return
+	}
+
+	command, text := form.Get("command"), form.Get("text")
+	log.Printf("commands: %s %s by %s", command, text, form.Get("user_id"))
+
+	// Help and unknown queries are answered right away; the others may
+	// take longer than Slack waits.
+	responseURL := form.Get("response_url")
+	if _, ok := h.query(text); ok && responseURL != "" {
+		go func() {
+			resp := slack.Response{SlackMessage: h.Answer(command, text), ResponseType: slack.Ephemeral}
+			if err := slack.Respond(h.Client, responseURL, resp); err != nil {
+				log.Printf("commands: failed to answer %s %s: %v", command, text, err)
+			}
+		}()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(slack.Response{SlackMessage: h.Answer(command, text), ResponseType: slack.Ephemeral}); err != nil {
+		log.Printf("commands: %v", err)
+	}
+}
+
+// Answer runs the query in text, such as "status web-03".
+func (h *Handler) Answer(command, text string) slack.SlackMessage {
+	args := strings.Fields(text)
+	if len(args) == 0 || args[0] == "help" {
+		return h.help(command)
+	}
+
+	q, ok := h.query(text)
+	if !ok {
+		return Text(fmt.Sprintf("Unknown query `%s`. Try `%s help`.", args[0], command))
+	}
+
+	msg, err := q.Run(args[1:])
+	if err != nil {
+		return Text(fmt.Sprintf(":warning: `%s %s` failed: %v", command, args[0], err))
+	}
+	return msg
+}
+
+// query returns the query named by the first word of text.
+func (h *Handler) query(text string) (Query, bool) {
+	args := strings.Fields(text)
+	if len(args) == 0 {
+		return Query{}, false
+	}
+	q, ok := h.Queries[args[0]]
+	return q, ok
+}
+
+func (h *Handler) help(command string) slack.SlackMessage {
+	names := make([]string, 0, len(h.Queries))
+	for name := range h.Queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{"Queries:"}
+	for _, name := range names {
+		q := h.Queries[name]
+		usage := strings.TrimSpace(command + " " + name + " " + q.Usage)
+		lines = append(lines, fmt.Sprintf("`%s`  %s", usage, q.Help))
+	}
+	return Text(strings.Join(lines, "\n"))
+}
+
+// Text returns a message with a single mrkdwn section.
+func Text(text string) slack.SlackMessage {
+	return slack.SlackMessage{
+		Text:   text,
+		Blocks: []slack.Block{{Type: "section", Text: &slack.TextBlock{Type: "mrkdwn", Text: text}}},
+	}
+}
@@ -0,0 +1,170 @@
+package chatops
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/slack"
+)
+
+const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+var now = time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+func newHandler() *Handler {
+	return &Handler{
+		SigningSecret: secret,
+		Client:        http.DefaultClient,
+		Now:           func() time.Time { return now },
+		Queries: map[string]Query{
+			"status": {
+				Usage: "[host]",
+				Help:  "how this host is doing",
+				Run: func(args []string) (slack.SlackMessage, error) {
+					return Text("status of " + strings.Join(args, " ")), nil
+				},
+			},
+			"broken": {
+				Help: "always fails",
+				Run: func([]string) (slack.SlackMessage, error) {
+					return slack.SlackMessage{}, errors.New("disk on fire")
+				},
+			},
+		},
+	}
+}
+
+// command sends the fixture slash command with text, signed with key, and
+// returns the answer, whether it comes in the reply or to the response URL.
+func command(t *testing.T, h *Handler, text, key string) (int, slack.Response) {
+	t.Helper()
+	answers := make(chan slack.Response, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp slack.Response
+		if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+			t.Error(err)
+		}
+		answers <- resp
+	}))
+	defer srv.Close()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request(t, text, key, srv.URL))
+
+	var resp slack.Response
+	if w.Code != http.StatusOK {
+		return w.Code, resp
+	}
+	if w.Body.Len() > 0 {
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, resp
+	}
+	select {
+	case resp = <-answers:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no answer to the response URL")
+	}
+	return w.Code, resp
+}
+
+// request returns the fixture slash command with text and responseURL,
+// signed with key.
+func request(t *testing.T, text, key, responseURL string) *http.Request {
+	t.Helper()
+	data, err := os.ReadFile("testdata/command.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Replace(strings.TrimSpace(string(data)), "TEXT", url.QueryEscape(text), 1)
+	body = strings.Replace(body, url.QueryEscape("https://hooks.slack.com/commands/1234/5678"), url.QueryEscape(responseURL), 1)
+
+	r := httptest.NewRequest(http.MethodPost, "/slack/commands", strings.NewReader(body))
+	r.Header.Set(slack.HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	r.Header.Set(slack.HeaderSignature, slack.Sign(key, now.Unix(), []byte(body)))
+	return r
+}
+
+func TestCommand(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"status web-03", "status of web-03"},
+		{"  status   ", "status of "},
+		{"", "Queries:\n`/slackbot broken`  always fails\n`/slackbot status [host]`  how this host is doing"},
+		{"help", "Queries:\n`/slackbot broken`  always fails\n`/slackbot status [host]`  how this host is doing"},
+		{"rm -rf /", "Unknown query `rm`. Try `/slackbot help`."},
+		{"broken", ":warning: `/slackbot broken` failed: disk on fire"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			code, resp := command(t, newHandler(), c.text, secret)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d", code)
+			}
+			if resp.ResponseType != slack.Ephemeral || resp.Text != c.want {
+				t.Errorf("response = %s %q, want ephemeral %q", resp.ResponseType, resp.Text, c.want)
+			}
+		})
+	}
+}
+
+func TestCommandRejectsUnsigned(t *testing.T) {
+	ran := false
+	h := newHandler()
+	h.Queries["status"] = Query{Run: func([]string) (slack.SlackMessage, error) {
+		ran = true
+		return slack.SlackMessage{}, nil
+	}}
+
+	if code, _ := command(t, h, "status", "not the secret"); code != http.StatusUnauthorized || ran {
+		t.Errorf("status = %d, ran = %v, want 401 without running the query", code, ran)
+	}
+}
+
+func TestCommandAnswersLater(t *testing.T) {
+	answers := make(chan slack.Response, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp slack.Response
+		if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+			t.Error(err)
+		}
+		answers <- resp
+	}))
+	defer srv.Close()
+
+	release := make(chan struct{})
+	h := newHandler()
+	h.Queries["slow"] = Query{Run: func([]string) (slack.SlackMessage, error) {
+		<-release
+		return Text("done"), nil
+	}}
+
+	// The request is acknowledged while the query still runs
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request(t, "slow", secret, srv.URL))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("reply = %d %q, want an empty 200", w.Code, w.Body)
+	}
+
+	close(release)
+	select {
+	case resp := <-answers:
+		if resp.ResponseType != slack.Ephemeral || resp.Text != "done" {
+			t.Errorf("response = %s %q, want ephemeral %q", resp.ResponseType, resp.Text, "done")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no answer to the response URL")
+	}
+}
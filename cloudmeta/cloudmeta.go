@@ -0,0 +1,421 @@
+// Package cloudmeta identifies the cloud instance slackbot runs on by probing
+// the instance metadata services of AWS, GCP, Azure and DigitalOcean.
+package cloudmeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+// Provider names
+const (
+	AWS          = "aws"
+	GCP          = "gcp"
+	Azure        = "azure"
+	DigitalOcean = "digitalocean"
+
+	cacheName = "cloud"
+)
+
+// Info describes a cloud instance. Fields a provider doesn't expose are empty.
+type Info struct {
+	Provider     string `json:"provider"`
+	InstanceID   string `json:"instance_id"`
+	Name         string `json:"name,omitempty"`
+	Region       string `json:"region,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	InstanceType string `json:"instance_type,omitempty"`
+	Account      string `json:"account,omitempty"` // AWS account, GCP project or Azure subscription
+}
+
+// Endpoints are the base URLs of the metadata services.
+type Endpoints struct {
+	AWS          string
+	GCP          string
+	Azure        string
+	DigitalOcean string
+}
+
+// DefaultEndpoints returns the well-known metadata service addresses.
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		AWS:          "http://169.254.169.254",
+		GCP:          "http://metadata.google.internal",
+		Azure:        "http://169.254.169.254",
+		DigitalOcean: "http://169.254.169.254",
+	}
+}
+
+// Options configures detection.
+type Options struct {
+	Providers  []string      // providers to probe, default all
+	Endpoints  Endpoints     // default DefaultEndpoints
+	Timeout    time.Duration // deadline for all probes, default 500ms
+	CacheTTL   time.Duration // default 24h
+	FailureTTL time.Duration // how long a probe without an answer is remembered, default 10m
+	Cache      *state.Store  // nil disables caching
+	Client     *http.Client  // default a client that never uses a proxy
+}
+
+// probe fetches instance metadata from one provider.
+type probe func(ctx context.Context, c *http.Client, baseURL string) (*Info, error)
+
+var probes = map[string]probe{
+	AWS:          probeAWS,
+	GCP:          probeGCP,
+	Azure:        probeAzure,
+	DigitalOcean: probeDigitalOcean,
+}
+
+// ValidateProvider checks a provider name.
+func ValidateProvider(name string) error {
+	if _, ok := probes[name]; !ok {
+		return fmt.Errorf("unknown cloud provider %q, want aws, gcp, azure or digitalocean", name)
+	}
+	return nil
+}
+
+func (o Options) withDefaults() Options {
+	if len(o.Providers) == 0 {
+		o.Providers = []string{AWS, GCP, Azure, DigitalOcean}
+	}
+	d := DefaultEndpoints()
+	if o.Endpoints.AWS == "" {
+		o.Endpoints.AWS = d.AWS
+	}
+	if o.Endpoints.GCP == "" {
+		o.Endpoints.GCP = d.GCP
+	}
+	if o.Endpoints.Azure == "" {
+		o.Endpoints.Azure = d.Azure
+	}
+	if o.Endpoints.DigitalOcean == "" {
+		o.Endpoints.DigitalOcean = d.DigitalOcean
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 500 * time.Millisecond
+	}
+	if o.CacheTTL == 0 {
+		o.CacheTTL = 24 * time.Hour
+	}
+	if o.FailureTTL == 0 {
+		o.FailureTTL = 10 * time.Minute
+	}
+	if o.Client == nil {
+		// Metadata services are link-local and must never go through a proxy
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		o.Client = &http.Client{Transport: transport}
+	}
+	return o
+}
+
+func (o Options) baseURL(provider string) string {
+	switch provider {
+	case AWS:
+		return o.Endpoints.AWS
+	case GCP:
+		return o.Endpoints.GCP
+	case Azure:
+		return o.Endpoints.Azure
+	default:
+		return o.Endpoints.DigitalOcean
+	}
+}
+
+// cacheEntry remembers the last detection; a nil Info means "not a cloud
+// instance", unless Error says why no probe got an answer.
+type cacheEntry struct {
+	Info      *Info     `json:"info"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Detect probes the metadata services at once and returns the first
+// answer. It returns nil and no error when the host isn't a cloud instance,
+// which is only concluded when every service was definitely missing. A
+// service that didn't answer in time, as at boot, is tried again after
+// FailureTTL.
+func Detect(ctx context.Context, opts Options) (*Info, error) {
+	opts = opts.withDefaults()
+
+	// A cache that can't be read is replaced, with a fresh detection
+	if opts.Cache != nil {
+		var entry cacheEntry
+		err := opts.Cache.Load(cacheName, &entry)
+		age := time.Since(entry.CheckedAt)
+		switch {
+		case err != nil || entry.CheckedAt.IsZero():
+		case entry.Error != "" && age < opts.FailureTTL:
+			return nil, errors.New(entry.Error)
+		case entry.Error == "" && age < opts.CacheTTL:
+			return entry.Info, nil
+		}
+	}
+
+	info, err := race(ctx, opts)
+	var unknown *errUnknown
+	if err != nil && !errors.As(err, &unknown) {
+		return nil, err
+	}
+
+	if opts.Cache != nil {
+		entry := cacheEntry{Info: info, CheckedAt: time.Now()}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if err := opts.Cache.Save(cacheName, entry); err != nil {
+			return info, fmt.Errorf("failed to cache cloud metadata: %w", err)
+		}
+	}
+
+	return info, err
+}
+
+// errUnknown means that no probe got an answer, but not every service was
+// definitely missing either.
+type errUnknown struct {
+	err error
+}
+
+func (e *errUnknown) Error() string {
+	return "no metadata service answered: " + e.err.Error()
+}
+
+func (e *errUnknown) Unwrap() error {
+	return e.err
+}
+
+// statusError is a response other than 200 from a metadata service.
+type statusError struct {
+	url    string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status %s", e.url, e.status)
+}
+
+// missing reports whether err shows that there is no metadata service:
+// the address refused the connection or doesn't exist, or the service
+// refused the request.
+func missing(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code >= 400 && status.code < 500 && status.code != http.StatusTooManyRequests
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsNotFound
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH)
+}
+
+func race(ctx context.Context, opts Options) (*Info, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	type result struct {
+		info *Info
+		err  error
+	}
+	results := make(chan result, len(opts.Providers))
+
+	for _, name := range opts.Providers {
+		p, ok := probes[name]
+		if !ok {
+			return nil, ValidateProvider(name)
+		}
+		go func() {
+			info, err := p(ctx, opts.Client, opts.baseURL(name))
+			results <- result{info, err}
+		}()
+	}
+
+	var errs []error
+	for range opts.Providers {
+		r := <-results
+		if r.err == nil {
+			return r.info, nil
+		}
+		if !missing(r.err) {
+			errs = append(errs, r.err)
+		}
+	}
+
+	// Every service missing is the normal outcome outside a cloud
+	if len(errs) > 0 {
+		return nil, &errUnknown{errors.Join(errs...)}
+	}
+	return nil, nil
+}
+
+// get performs a metadata request and returns the body of a 200 response.
+func get(ctx context.Context, c *http.Client, method, url string, header map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{url: url, status: resp.Status, code: resp.StatusCode}
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func getJSON(ctx context.Context, c *http.Client, url string, header map[string]string, v any) error {
+	body, err := get(ctx, c, http.MethodGet, url, header)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// probeAWS uses IMDSv2: a session token first, then the identity document.
+func probeAWS(ctx context.Context, c *http.Client, base string) (*Info, error) {
+	token, err := get(ctx, c, http.MethodPut, base+"/latest/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err != nil {
+		return nil, err
+	}
+	header := map[string]string{"X-aws-ec2-metadata-token": string(token)}
+
+	var doc struct {
+		InstanceID       string `json:"instanceId"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceType     string `json:"instanceType"`
+		AccountID        string `json:"accountId"`
+	}
+	if err := getJSON(ctx, c, base+"/latest/dynamic/instance-identity/document", header, &doc); err != nil {
+		return nil, err
+	}
+	if doc.InstanceID == "" {
+		return nil, errors.New("aws: identity document without an instance ID")
+	}
+
+	// Tags are only exposed when the instance allows it
+	name, _ := get(ctx, c, http.MethodGet, base+"/latest/meta-data/tags/instance/Name", header)
+
+	return &Info{
+		Provider:     AWS,
+		InstanceID:   doc.InstanceID,
+		Name:         strings.TrimSpace(string(name)),
+		Region:       doc.Region,
+		Zone:         doc.AvailabilityZone,
+		InstanceType: doc.InstanceType,
+		Account:      doc.AccountID,
+	}, nil
+}
+
+func probeGCP(ctx context.Context, c *http.Client, base string) (*Info, error) {
+	header := map[string]string{"Metadata-Flavor": "Google"}
+
+	var instance struct {
+		ID          json.Number `json:"id"`
+		Name        string      `json:"name"`
+		Zone        string      `json:"zone"`        // projects/123/zones/us-central1-a
+		MachineType string      `json:"machineType"` // projects/123/machineTypes/e2-medium
+	}
+	if err := getJSON(ctx, c, base+"/computeMetadata/v1/instance/?recursive=true", header, &instance); err != nil {
+		return nil, err
+	}
+	if instance.ID == "" {
+		return nil, errors.New("gcp: instance metadata without an ID")
+	}
+
+	project, _ := get(ctx, c, http.MethodGet, base+"/computeMetadata/v1/project/project-id", header)
+
+	zone := lastSegment(instance.Zone)
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+
+	return &Info{
+		Provider:     GCP,
+		InstanceID:   instance.ID.String(),
+		Name:         instance.Name,
+		Region:       region,
+		Zone:         zone,
+		InstanceType: lastSegment(instance.MachineType),
+		Account:      strings.TrimSpace(string(project)),
+	}, nil
+}
+
+func probeAzure(ctx context.Context, c *http.Client, base string) (*Info, error) {
+	var doc struct {
+		Compute struct {
+			VMID           string `json:"vmId"`
+			Name           string `json:"name"`
+			Location       string `json:"location"`
+			Zone           string `json:"zone"`
+			VMSize         string `json:"vmSize"`
+			SubscriptionID string `json:"subscriptionId"`
+		} `json:"compute"`
+	}
+	err := getJSON(ctx, c, base+"/metadata/instance?api-version=2021-02-01",
+		map[string]string{"Metadata": "true"}, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Compute.VMID == "" {
+		return nil, errors.New("azure: instance metadata without a VM ID")
+	}
+
+	return &Info{
+		Provider:     Azure,
+		InstanceID:   doc.Compute.VMID,
+		Name:         doc.Compute.Name,
+		Region:       doc.Compute.Location,
+		Zone:         doc.Compute.Zone,
+		InstanceType: doc.Compute.VMSize,
+		Account:      doc.Compute.SubscriptionID,
+	}, nil
+}
+
+func probeDigitalOcean(ctx context.Context, c *http.Client, base string) (*Info, error) {
+	var doc struct {
+		DropletID json.Number `json:"droplet_id"`
+		Hostname  string      `json:"hostname"`
+		Region    string      `json:"region"`
+	}
+	if err := getJSON(ctx, c, base+"/metadata/v1.json", nil, &doc); err != nil {
+		return nil, err
+	}
+	if doc.DropletID == "" {
+		return nil, errors.New("digitalocean: metadata without a droplet ID")
+	}
+
+	return &Info{
+		Provider:   DigitalOcean,
+		InstanceID: doc.DropletID.String(),
+		Name:       doc.Hostname,
+		Region:     doc.Region,
+	}, nil
+}
+
+func lastSegment(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
@@ -0,0 +1,202 @@
+package cloudmeta
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+const awsToken = "test-token"
+
+// metadataServer is a local stand-in for the metadata services. Each
+// handler checks the headers the real service requires.
+func metadataServer(t *testing.T, provider string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	switch provider {
+	case AWS:
+		mux.HandleFunc("PUT /latest/api/token", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				http.Error(w, "missing TTL", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, awsToken)
+		})
+		requireToken := func(body string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-aws-ec2-metadata-token") != awsToken {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				fmt.Fprint(w, body)
+			}
+		}
+		mux.HandleFunc("GET /latest/dynamic/instance-identity/document", requireToken(`{
+			"accountId": "123456789012",
+			"availabilityZone": "eu-west-1b",
+			"instanceId": "i-0abc123",
+			"instanceType": "t3.small",
+			"region": "eu-west-1"
+		}`))
+		mux.HandleFunc("GET /latest/meta-data/tags/instance/Name", requireToken("web-prod-3"))
+	case GCP:
+		requireFlavor := func(body string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Metadata-Flavor") != "Google" {
+					http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+					return
+				}
+				fmt.Fprint(w, body)
+			}
+		}
+		mux.HandleFunc("GET /computeMetadata/v1/instance/", requireFlavor(`{
+			"id": 4520031799277581759,
+			"name": "batch-7",
+			"zone": "projects/998/zones/us-central1-a",
+			"machineType": "projects/998/machineTypes/e2-medium"
+		}`))
+		mux.HandleFunc("GET /computeMetadata/v1/project/project-id", requireFlavor("acme-prod"))
+	case Azure:
+		mux.HandleFunc("GET /metadata/instance", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata") != "true" {
+				http.Error(w, "missing Metadata header", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"compute": {
+				"vmId": "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+				"name": "api-vm",
+				"location": "westeurope",
+				"zone": "2",
+				"vmSize": "Standard_D2s_v3",
+				"subscriptionId": "8d10da13-8125-4ba9-a717-bf7490507b3d"
+			}}`)
+		})
+	case DigitalOcean:
+		mux.HandleFunc("GET /metadata/v1.json", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"droplet_id": 2756294, "hostname": "sample-droplet", "region": "nyc3"}`)
+		})
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func endpoints(url string) Endpoints {
+	return Endpoints{AWS: url, GCP: url, Azure: url, DigitalOcean: url}
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		provider string
+		want     Info
+	}{
+		{AWS, Info{
+			Provider: AWS, InstanceID: "i-0abc123", Name: "web-prod-3", Region: "eu-west-1",
+			Zone: "eu-west-1b", InstanceType: "t3.small", Account: "123456789012",
+		}},
+		{GCP, Info{
+			Provider: GCP, InstanceID: "4520031799277581759", Name: "batch-7", Region: "us-central1",
+			Zone: "us-central1-a", InstanceType: "e2-medium", Account: "acme-prod",
+		}},
+		{Azure, Info{
+			Provider: Azure, InstanceID: "02aab8a4-74ef-476e-8182-f6d2ba4166a6", Name: "api-vm", Region: "westeurope",
+			Zone: "2", InstanceType: "Standard_D2s_v3", Account: "8d10da13-8125-4ba9-a717-bf7490507b3d",
+		}},
+		{DigitalOcean, Info{
+			Provider: DigitalOcean, InstanceID: "2756294", Name: "sample-droplet", Region: "nyc3",
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.provider, func(t *testing.T) {
+			server := metadataServer(t, c.provider)
+
+			// Every provider is probed against the same server; only one may answer
+			info, err := Detect(context.Background(), Options{Endpoints: endpoints(server.URL), Timeout: time.Second})
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if info == nil || *info != c.want {
+				t.Errorf("Detect() = %+v, want %+v", info, c.want)
+			}
+		})
+	}
+}
+
+func TestDetectNotInCloud(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	info, err := Detect(context.Background(), Options{Endpoints: endpoints(server.URL), Timeout: time.Second})
+	if err != nil || info != nil {
+		t.Errorf("Detect() = %+v, %v; want nil, nil", info, err)
+	}
+}
+
+func TestDetectCachesResult(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	opts := Options{
+		Providers: []string{DigitalOcean},
+		Endpoints: endpoints(server.URL),
+		Cache:     &state.Store{Dir: t.TempDir()},
+	}
+	for i := 0; i < 3; i++ {
+		if info, err := Detect(context.Background(), opts); err != nil || info != nil {
+			t.Fatalf("run %d: Detect() = %+v, %v", i, info, err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("metadata service was queried %d times, want 1", n)
+	}
+}
+
+func TestDetectRetriesTimeouts(t *testing.T) {
+	var slow atomic.Bool
+	slow.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			time.Sleep(200 * time.Millisecond)
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	cache := &state.Store{Dir: t.TempDir()}
+	opts := Options{
+		Providers: []string{DigitalOcean},
+		Endpoints: endpoints(server.URL),
+		Timeout:   50 * time.Millisecond,
+		Cache:     cache,
+	}
+	if info, err := Detect(context.Background(), opts); err == nil || info != nil {
+		t.Fatalf("Detect() with a slow service = %+v, %v; want an error", info, err)
+	}
+	if _, err := Detect(context.Background(), opts); err == nil {
+		t.Error("the failure should be remembered for FailureTTL")
+	}
+
+	// Once the failure expires, a definite answer is cached as usual
+	slow.Store(false)
+	opts.FailureTTL = time.Nanosecond
+	if info, err := Detect(context.Background(), opts); err != nil || info != nil {
+		t.Errorf("Detect() after FailureTTL = %+v, %v; want nil, nil", info, err)
+	}
+	var entry cacheEntry
+	if err := cache.Load(cacheName, &entry); err != nil || entry.Error != "" {
+		t.Errorf("cache = %+v, %v; want a definite negative", entry, err)
+	}
+}
@@ -0,0 +1,166 @@
+package slackbot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/chatops"
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/history"
+	"github.com/maxkulish/slackbot/hostfacts"
+	"github.com/maxkulish/slackbot/mute"
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
+)
+
+// statusAlerts is how many recent alerts the status query lists.
+const statusAlerts = 10
+
+// queries returns the read-only queries allowed by the config.
+func (c *CMD) queries(conf *config.Config, store *state.Store) map[string]chatops.Query {
+	all := map[string]chatops.Query{
+		"status": {
+			Usage: "[host]",
+			Help:  "hostname, IPs, uptime and the last alerts",
+			Run:   func(args []string) (slack.SlackMessage, error) { return c.statusQuery(conf, store, args) },
+		},
+		"oncall": {
+			Help: "who is on call now and who is next",
+			Run:  func([]string) (slack.SlackMessage, error) { return onCallQuery(conf) },
+		},
+		"maintenance": {
+			Help: "open maintenance windows and held messages",
+			Run:  func([]string) (slack.SlackMessage, error) { return c.maintenanceQuery(conf) },
+		},
+		"mutes": {
+			Help: "alerts muted from Slack",
+			Run:  func([]string) (slack.SlackMessage, error) { return mutesQuery(conf, store) },
+		},
+	}
+
+	allowed := map[string]chatops.Query{}
+	for _, name := range conf.Serve.QueryNames() {
+		if q, ok := all[name]; ok {
+			allowed[name] = q
+		}
+	}
+	return allowed
+}
+
+// statusQuery describes this host like an alert does, with facts and the
+// last alerts sent from it.
+func (c *CMD) statusQuery(conf *config.Config, store *state.Store, args []string) (slack.SlackMessage, error) {
+	hostname, err := c.getHostname()
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+	if len(args) > 1 {
+		return slack.SlackMessage{}, fmt.Errorf("want at most one host")
+	}
+	if len(args) == 1 && args[0] != hostname {
+		return chatops.Text(fmt.Sprintf("This is *%s*; `%s` answers for itself.", hostname, args[0])), nil
+	}
+
+	ips, err := c.getIPAddrs(conf)
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+
+	opts := c.hostContext(conf)
+	if !conf.HostFacts {
+		facts, _ := hostfacts.Reader{}.Read()
+		opts = append(opts, slack.WithFacts(facts))
+	}
+
+	recent, err := history.Store{State: store}.Recent(statusAlerts)
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+	loc, err := conf.Location()
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+
+	lines := []string{"Last alerts:"}
+	for _, e := range recent {
+		lines = append(lines, fmt.Sprintf("%s  %-8s  %-7s  %s", e.Time.In(loc).Format(config.TimeLayout), e.Severity, e.Source, e.Summary))
+	}
+	if len(recent) == 0 {
+		lines = []string{"No alerts sent recently."}
+	}
+
+	return slack.PrepareMessage(hostname, "\n"+strings.Join(lines, "\n"), ips, opts...), nil
+}
+
+func onCallQuery(conf *config.Config) (slack.SlackMessage, error) {
+	schedule, ok, err := conf.OnCallSchedule()
+	if err != nil {
+		return slack.SlackMessage{}, err
+	} else if !ok {
+		return chatops.Text("No on-call rotation configured."), nil
+	}
+
+	cur, ok := schedule.At(time.Now())
+	if !ok {
+		return chatops.Text("Nobody is on call."), nil
+	}
+	lines := []string{fmt.Sprintf("On call: %s until %s", describeMember(conf, cur), cur.To.Format(config.TimeLayout+" MST"))}
+	if next, ok := schedule.Next(cur); ok {
+		lines = append(lines, fmt.Sprintf("Next: %s until %s", describeMember(conf, next), next.To.Format(config.TimeLayout+" MST")))
+	}
+	return chatops.Text(strings.Join(lines, "\n")), nil
+}
+
+func (c *CMD) maintenanceQuery(conf *config.Config) (slack.SlackMessage, error) {
+	gate, err := c.gate(conf)
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+	windows, err := gate.Windows(time.Now())
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+	hostname, err := c.getHostname()
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+	held, err := gate.Pending(hostname, time.Now())
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+
+	var lines []string
+	for _, w := range windows {
+		line := fmt.Sprintf(":construction: *%s* until %s, host `%s`", w.Name(), w.Until.Format(config.TimeLayout), orAll(w.Host))
+		if w.Reason != "" {
+			line += ": " + w.Reason
+		}
+		lines = append(lines, line)
+	}
+	if len(windows) == 0 {
+		lines = append(lines, "No maintenance windows open.")
+	}
+	lines = append(lines, plural(held, "message")+" held.")
+	return chatops.Text(strings.Join(lines, "\n")), nil
+}
+
+func mutesQuery(conf *config.Config, store *state.Store) (slack.SlackMessage, error) {
+	mutes, err := mute.Store{State: store}.List(time.Now())
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+	loc, err := conf.Location()
+	if err != nil {
+		return slack.SlackMessage{}, err
+	}
+
+	var lines []string
+	for _, m := range mutes {
+		lines = append(lines, fmt.Sprintf(":mute: `%s` on %s until %s by <@%s>", m.Key, m.Host, m.Until.In(loc).Format(config.TimeLayout), m.By))
+	}
+	if len(mutes) == 0 {
+		lines = append(lines, "Nothing is muted.")
+	}
+	return chatops.Text(strings.Join(lines, "\n")), nil
+}
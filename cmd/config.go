@@ -0,0 +1,79 @@
+package slackbot
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runConfig implements `slackbot config <show|validate>`.
+func (c *CMD) runConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: slackbot config show [--origin] | validate")
+	}
+
+	switch args[0] {
+	case "show":
+		return c.runConfigShow(args[1:])
+	case "validate":
+		return c.runConfigValidate(args[1:])
+	default:
+		return fmt.Errorf("unknown config command %q", args[0])
+	}
+}
+
+func (c *CMD) runConfigShow(args []string) error {
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	origin := fs.Bool("origin", false, "Annotate every value with the layer it came from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Show references as written; resolving them would print secrets
+	opts := c.configOptions()
+	opts.SkipResolve = true
+
+	conf, err := c.loadConfigWith(opts)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	out, err := conf.Show(*origin)
+	if err != nil {
+		return err
+	}
+
+	if *origin {
+		fmt.Println("# layers, lowest precedence first:")
+		for _, s := range conf.Sources {
+			fmt.Printf("#   %s\n", s)
+		}
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// runConfigValidate loads every layer strictly, resolves secret references
+// and checks URLs and destination references, printing all problems found.
+func (c *CMD) runConfigValidate(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: slackbot config validate")
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	errs := conf.Validate()
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %d problem(s) found", len(errs))
+	}
+
+	fmt.Printf("configuration is valid (%s)\n", strings.Join(conf.Sources, ", "))
+	return nil
+}
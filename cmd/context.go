@@ -0,0 +1,66 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/maxkulish/slackbot/cloudmeta"
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/container"
+	"github.com/maxkulish/slackbot/hostfacts"
+	"github.com/maxkulish/slackbot/httpclient"
+	"github.com/maxkulish/slackbot/slack"
+)
+
+// hostContext gathers the optional host details enabled in the config.
+// Failures are logged and never stop a message from being sent.
+func (c *CMD) hostContext(conf *config.Config) []slack.MessageOption {
+	var opts []slack.MessageOption
+
+	// Metadata services are on the network
+	if conf.Cloud.Enabled && !c.noNetwork() {
+		info, err := c.cloudInfo(conf)
+		if err != nil {
+			log.Printf("failed to get cloud metadata: %v", err)
+		}
+		opts = append(opts, slack.WithCloud(info))
+	}
+
+	if !conf.Container.Disabled {
+		info, err := container.Detector{LabelsFile: conf.Container.LabelsFile}.Detect()
+		if err != nil {
+			log.Printf("failed to detect container context: %v", err)
+		}
+		opts = append(opts, slack.WithContainer(info))
+	}
+
+	if conf.HostFacts {
+		facts, err := hostfacts.Reader{}.Read()
+		if err != nil {
+			log.Printf("failed to read some host facts: %v", err)
+		}
+		opts = append(opts, slack.WithFacts(facts))
+	}
+
+	return opts
+}
+
+func (c *CMD) cloudInfo(conf *config.Config) (*cloudmeta.Info, error) {
+	// Metadata services are link-local and must never go through a proxy
+	httpOpts := conf.HTTP.Options()
+	httpOpts.NoProxy = append(httpOpts.NoProxy, "*")
+	client, err := httpclient.New(httpOpts)
+	if err != nil {
+		return nil, fmt.Errorf("http: %w", err)
+	}
+
+	return cloudmeta.Detect(context.Background(), cloudmeta.Options{
+		Providers:  conf.Cloud.Providers,
+		Timeout:    conf.Cloud.Timeout,
+		CacheTTL:   conf.Cloud.CacheTTL,
+		FailureTTL: conf.Cloud.FailureTTL,
+		Cache:      cacheStore(conf),
+		Client:     client,
+	})
+}
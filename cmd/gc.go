@@ -0,0 +1,83 @@
+package slackbot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/sent"
+	"github.com/maxkulish/slackbot/slack"
+)
+
+// runGC implements `slackbot gc`: it removes the messages sent with -ttl
+// that have expired.
+func (c *CMD) runGC(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: slackbot gc")
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	removed, err := c.collect(conf, time.Now())
+	fmt.Println(plural(removed, "expired message") + " removed")
+	return err
+}
+
+// collect deletes the messages that have expired by now, or collapses them
+// to a note, and returns how many it removed. Messages that fail are kept
+// for the next run, unless they're gone already.
+func (c *CMD) collect(conf *config.Config, now time.Time) (int, error) {
+	p, err := newPoster(conf)
+	if err != nil {
+		return 0, err
+	} else if p.stateErr != nil {
+		return 0, p.stateErr
+	}
+	expired, err := p.sent.Expired(now)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, m := range expired {
+		d, ok := conf.Destination(m.Destination)
+		if !ok || !d.WebAPI() {
+			log.Printf("can't remove message %s: destination %s has no token anymore", m.TS, m.Destination)
+			if err := p.sent.Forget(m); err != nil {
+				return removed, err
+			}
+			continue
+		}
+
+		if err := expire(p, conf.Expire, d.Token, m); err != nil && !slack.IsMessageGone(err) {
+			log.Printf("failed to remove message %s from %s: %v", m.TS, m.Destination, err)
+			continue
+		}
+		if err := p.sent.Forget(m); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// expire deletes m or replaces it with a one-line note.
+func expire(p poster, e config.ExpireConfig, token string, m sent.Message) error {
+	api := slack.API{Client: p.client, Token: token}
+	if e.ActionName() == config.ExpireDelete {
+		return api.DeleteMessage(m.Channel, m.TS)
+	}
+
+	note := e.NoteText()
+	if m.Summary != "" {
+		note += "  ~" + m.Summary + "~"
+	}
+	return api.UpdateMessage(m.Channel, m.TS, slack.SlackMessage{
+		Text:   note,
+		Blocks: []slack.Block{{Type: "context", Elements: []slack.Element{{Type: "mrkdwn", Text: note}}}},
+	})
+}
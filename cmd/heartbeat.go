@@ -0,0 +1,143 @@
+package slackbot
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/heartbeat"
+	"github.com/maxkulish/slackbot/interactions"
+	"github.com/maxkulish/slackbot/rules"
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
+)
+
+const heartbeatUsage = "usage: slackbot heartbeat <name> [--expect 1h] | <name> --remove | heartbeat"
+
+// runHeartbeat implements `slackbot heartbeat`: it records a ping of a
+// job, forgets one, or lists them all.
+func (c *CMD) runHeartbeat(args []string) error {
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("heartbeat", flag.ContinueOnError)
+	expect := fs.Duration("expect", 0, "Longest time between pings, e.g. 1h; needed for the first ping")
+	remove := fs.Bool("remove", false, "Forget the heartbeat")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 || (name == "" && (*expect != 0 || *remove)) || *expect < 0 {
+		return fmt.Errorf("%s", heartbeatUsage)
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	store, err := state.Open(conf.StateDir)
+	if err != nil {
+		return err
+	}
+	beats := heartbeat.Store{State: store}
+
+	switch {
+	case name == "":
+		return c.runHeartbeatList(conf, beats)
+	case *remove:
+		if found, err := beats.Remove(name); err != nil {
+			return err
+		} else if !found {
+			return fmt.Errorf("no heartbeat %q", name)
+		}
+		return nil
+	}
+
+	if _, ok, err := beats.Ping(name, *expect, time.Now()); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("first ping of %q: --expect is required", name)
+	}
+	return nil
+}
+
+func (c *CMD) runHeartbeatList(conf *config.Config, beats heartbeat.Store) error {
+	loc, err := conf.Location()
+	if err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	list, err := beats.List()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, h := range list {
+		status := "ok"
+		if now.After(h.Due()) {
+			status = "overdue"
+		}
+		fmt.Printf("%-7s  %s  every %s  last %s\n", status, h.Name, interactions.FormatDuration(h.Expect), h.Last.In(loc).Format(config.TimeLayout))
+	}
+	return nil
+}
+
+// checkHeartbeats alerts about the heartbeats that are overdue by now and
+// the ones that came back since. A heartbeat is only marked once the
+// message went out, so that a failure is retried on the next check.
+func (c *CMD) checkHeartbeats(conf *config.Config, now time.Time) error {
+	store, err := state.Open(conf.StateDir)
+	if err != nil {
+		return err
+	}
+	beats := heartbeat.Store{State: store}
+	list, err := beats.List()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, h := range list {
+		var err error
+		switch {
+		case h.Late(now):
+			err = c.notify(conf, alert{
+				Text:     fmt.Sprintf("\nNo ping for %s, expected every %s.", since(h.Last, now), interactions.FormatDuration(h.Expect)),
+				Source:   rules.SourceHeartbeat,
+				Severity: severity.Error,
+				Options:  []slack.MessageOption{slack.WithSection(fmt.Sprintf(":skull: Heartbeat *%s* is overdue", h.Name))},
+			})
+			if err == nil {
+				err = beats.MarkOverdue(h.Name, now)
+			}
+		case h.Back():
+			err = c.notify(conf, alert{
+				Text:     fmt.Sprintf("\nPinged again after %s without a ping.", since(h.Last.Add(-h.Silence), h.Last)),
+				Source:   rules.SourceHeartbeat,
+				Severity: severity.Info,
+				Options:  []slack.MessageOption{slack.WithSection(fmt.Sprintf(":white_check_mark: Heartbeat *%s* is back", h.Name))},
+			})
+			if err == nil {
+				err = beats.MarkOverdue(h.Name, time.Time{})
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("heartbeat %s: %w", h.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// since formats the time from t to now to the minute, or to the second
+// if it's shorter.
+func since(t, now time.Time) string {
+	d := now.Sub(t)
+	if d < time.Minute {
+		return interactions.FormatDuration(d.Round(time.Second))
+	}
+	return interactions.FormatDuration(d.Round(time.Minute))
+}
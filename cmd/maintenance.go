@@ -0,0 +1,269 @@
+package slackbot
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/maintenance"
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
+)
+
+// summaryLines caps the held messages listed in a summary.
+const summaryLines = 20
+
+// runMaintenance implements `slackbot maintenance <start|end|list>`.
+func (c *CMD) runMaintenance(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: slackbot maintenance start --for 2h [--host glob] [--reason text] | end [id] | list")
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	gate, err := c.gate(conf)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "start":
+		return c.runMaintenanceStart(gate, args[1:])
+	case "end":
+		return c.runMaintenanceEnd(conf, gate, args[1:])
+	case "list":
+		return c.runMaintenanceList(conf, gate)
+	default:
+		return fmt.Errorf("unknown maintenance command %q", args[0])
+	}
+}
+
+func (c *CMD) runMaintenanceStart(gate *maintenance.Gate, args []string) error {
+	fs := flag.NewFlagSet("maintenance start", flag.ContinueOnError)
+	duration := fs.Duration("for", 0, "Length of the window, e.g. 2h")
+	host := fs.String("host", "", "Only hold messages from hosts matching this glob, e.g. db*")
+	reason := fs.String("reason", "", "Why, shown in the summary")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *duration <= 0 {
+		return fmt.Errorf("usage: slackbot maintenance start --for 2h [--host glob] [--reason text]")
+	}
+
+	now := time.Now()
+	if c.DryRun {
+		fmt.Printf("would start maintenance until %s\n", now.Add(*duration).Format(config.TimeLayout))
+		return nil
+	}
+	w, err := gate.Start(maintenance.Window{Host: *host, From: now, Until: now.Add(*duration), Reason: *reason})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("maintenance %s until %s\n", w.ID, w.Until.Format(config.TimeLayout))
+	return nil
+}
+
+// runMaintenanceEnd closes windows early and sends their summaries.
+func (c *CMD) runMaintenanceEnd(conf *config.Config, gate *maintenance.Gate, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: slackbot maintenance end [id]")
+	}
+	var id string
+	if len(args) == 1 {
+		id = args[0]
+	}
+
+	now := time.Now()
+	if c.DryRun {
+		// Only the summaries of windows that ended already can be shown
+		open, err := gate.Windows(now)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, w := range open {
+			if id == "" || w.ID == id {
+				fmt.Printf("would end maintenance %s\n", w.ID)
+				found = true
+			}
+		}
+		if id != "" && !found {
+			return fmt.Errorf("no open maintenance window %q", id)
+		}
+	} else {
+		ended, err := gate.End(id, now)
+		if err != nil {
+			return err
+		}
+		for _, w := range ended {
+			fmt.Printf("ended maintenance %s\n", w.ID)
+		}
+	}
+
+	hostname, err := c.getHostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+	return c.sendSummaries(conf, gate, hostname, now)
+}
+
+func (c *CMD) runMaintenanceList(conf *config.Config, gate *maintenance.Gate) error {
+	windows, err := gate.Windows(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, w := range windows {
+		host := w.Host
+		if host == "" {
+			host = "*"
+		}
+		fmt.Printf("%s  until %s  host %s  %s\n", w.ID, w.Until.Format(config.TimeLayout), host, w.Reason)
+	}
+	for _, q := range conf.Maintenance.QuietHours {
+		fmt.Printf("quiet hours %s  days %s  host %s\n", q.Time, orAll(strings.Join(q.Days, ",")), orAll(q.Host))
+	}
+
+	hostname, err := c.getHostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+	n, err := gate.Pending(hostname, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d messages held\n", n)
+	return nil
+}
+
+func orAll(s string) string {
+	if s == "" {
+		return "*"
+	}
+	return s
+}
+
+func (c *CMD) gate(conf *config.Config) (*maintenance.Gate, error) {
+	store, err := state.Open(conf.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	quiet, err := conf.Maintenance.Quiet()
+	if err != nil {
+		return nil, fmt.Errorf("maintenance.quiet_hours: %w", err)
+	}
+	loc, err := conf.Location()
+	if err != nil {
+		return nil, fmt.Errorf("timezone: %w", err)
+	}
+
+	return &maintenance.Gate{
+		Store:    store,
+		Quiet:    quiet,
+		Location: loc,
+		Policy:   conf.Maintenance.Policy,
+		Bypass:   conf.Maintenance.BypassSeverity,
+	}, nil
+}
+
+// releaseHeld sends the summaries of windows that have ended. A failure
+// is only logged so that it doesn't hold up the message being sent.
+func (c *CMD) releaseHeld(conf *config.Config, gate *maintenance.Gate, hostname string, now time.Time) {
+	if err := c.sendSummaries(conf, gate, hostname, now); err != nil {
+		log.Printf("failed to send maintenance summary: %v", err)
+	}
+}
+
+// sendSummaries sends the summaries of windows that have ended. Held
+// messages are only released once their summary was sent, and never by a
+// dry run, which only prints the summaries.
+func (c *CMD) sendSummaries(conf *config.Config, gate *maintenance.Gate, hostname string, now time.Time) error {
+	summaries, err := gate.Ended(hostname, now)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range summaries {
+		route := s.Route()
+		if len(route) == 0 {
+			route = conf.DefaultRoute()
+		}
+		if err := c.deliver(conf, hostname, route, tracking{}, summaryText(s), slack.WithSection(summaryTitle(s))); err != nil {
+			return err
+		}
+		if c.DryRun {
+			continue
+		}
+		if err := gate.Release(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseEnded sends the summaries of windows that have ended by now, for
+// the daemon, which doesn't wait for the next message.
+func (c *CMD) releaseEnded(conf *config.Config, now time.Time) error {
+	gate, err := c.gate(conf)
+	if err != nil {
+		return err
+	}
+	hostname, err := c.getHostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+	return c.sendSummaries(conf, gate, hostname, now)
+}
+
+func summaryTitle(s maintenance.Summary) string {
+	held, dropped := s.Counts()
+
+	var counts []string
+	if held > 0 {
+		counts = append(counts, plural(held, "message")+" held")
+	}
+	if dropped > 0 {
+		counts = append(counts, plural(dropped, "message")+" dropped")
+	}
+	title := fmt.Sprintf(":construction: *%s* ended: %s", s.Window, strings.Join(counts, ", "))
+	if s.Reason != "" {
+		title += "\n" + s.Reason
+	}
+	return title
+}
+
+// summaryText lists the first line of each held message.
+func summaryText(s maintenance.Summary) string {
+	var b strings.Builder
+	var listed int
+	for _, h := range s.Held {
+		if h.Dropped {
+			continue
+		}
+		if listed == summaryLines {
+			held, _ := s.Counts()
+			fmt.Fprintf(&b, "\n… %d more", held-listed)
+			break
+		}
+		first, _, _ := strings.Cut(strings.TrimLeft(h.Text, "\n"), "\n")
+		fmt.Fprintf(&b, "\n%s %-8s %s", h.Time.Format("15:04:05"), h.Severity, first)
+		listed++
+	}
+
+	if listed == 0 {
+		return "\n(no messages kept)"
+	}
+	return b.String()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,67 @@
+package slackbot
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/oncall"
+)
+
+// runOnCall implements `slackbot oncall`: it prints who is on call now
+// and who is next.
+func (c *CMD) runOnCall(args []string) error {
+	fs := flag.NewFlagSet("oncall", flag.ContinueOnError)
+	at := fs.String("at", "", "Show the rotation at this time, RFC 3339 or HH:MM today, default now")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := c.configOptions()
+	opts.SkipResolve = true
+	conf, err := c.loadConfigWith(opts)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	schedule, ok, err := conf.OnCallSchedule()
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("no on-call rotation configured")
+	}
+
+	loc, err := conf.OnCallLocation()
+	if err != nil {
+		return fmt.Errorf("oncall.timezone: %w", err)
+	}
+	when, err := parseAt(*at, time.Now().In(loc))
+	if err != nil {
+		return err
+	}
+
+	cur, ok := schedule.At(when)
+	if !ok {
+		fmt.Println("nobody is on call")
+		return nil
+	}
+	fmt.Printf("on call:  %s until %s\n", describeMember(conf, cur), cur.To.Format(config.TimeLayout+" MST"))
+
+	if next, ok := schedule.Next(cur); ok {
+		fmt.Printf("next:     %s until %s\n", describeMember(conf, next), next.To.Format(config.TimeLayout+" MST"))
+	}
+
+	return nil
+}
+
+func describeMember(conf *config.Config, s oncall.Shift) string {
+	out := s.Member
+	if m, ok := conf.Mention(s.Member); ok && m != s.Member {
+		out += " " + m
+	}
+	if s.Override {
+		out += " (override)"
+	}
+	return out
+}
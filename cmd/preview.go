@@ -0,0 +1,127 @@
+package slackbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/maxkulish/slackbot/preview"
+	"github.com/maxkulish/slackbot/slack"
+)
+
+// runPreview implements `slackbot preview`: it renders a message in the
+// terminal. Stdin holds either a JSON payload, as printed by -dry-run, or
+// message text, which is prepared with the host details as it would be
+// sent.
+func (c *CMD) runPreview(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	color := fs.String("color", "auto", "Use ANSI colors: auto, always or never")
+	width := fs.Int("width", 0, "Width of dividers and fields, default $COLUMNS or 80")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: slackbot preview [--color auto|always|never] [--width N] < message")
+	}
+
+	r := &preview.Renderer{Width: *width}
+	if r.Width == 0 {
+		r.Width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
+	switch *color {
+	case "auto":
+		r.Color = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	case "always":
+		r.Color = true
+	case "never":
+	default:
+		return fmt.Errorf("--color: want auto, always or never, got %q", *color)
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return renderPayloads(r, trimmed)
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	a, err := c.readText(conf, bytes.NewReader(data))
+	if err != nil {
+		return err
+	} else if a.Text == "" {
+		return fmt.Errorf("no input text provided")
+	}
+
+	// The input as it would be sent, without rules, mutes, maintenance
+	// windows or destinations, and without going over the network
+	c.offline = true
+	hostname, err := c.getHostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+	ips, err := c.getIPAddrs(conf)
+	if err != nil {
+		return fmt.Errorf("failed to get IP addresses: %w", err)
+	}
+
+	opts := append(c.hostContext(conf), slack.WithLimits(conf.Message.Limits()), slack.WithSeverity(c.Severity))
+	return r.Render(os.Stdout, slack.PrepareMessage(hostname, a.Text, ips, append(opts, a.Options...)...))
+}
+
+// renderPayloads renders a single payload, or each payload of an object
+// keyed by destination name.
+func renderPayloads(r *preview.Renderer, data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("invalid JSON payload: %w", err)
+	}
+
+	_, hasText := fields["text"]
+	_, hasBlocks := fields["blocks"]
+	_, hasAttachments := fields["attachments"]
+	if hasText || hasBlocks || hasAttachments {
+		var msg slack.SlackMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return fmt.Errorf("invalid JSON payload: %w", err)
+		}
+		return r.Render(os.Stdout, msg)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		var msg slack.SlackMessage
+		if err := json.Unmarshal(fields[name], &msg); err != nil {
+			return fmt.Errorf("invalid JSON payload for %s: %w", name, err)
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("» %s\n", name)
+		if err := r.Render(os.Stdout, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,138 @@
+package slackbot
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/rules"
+	"github.com/maxkulish/slackbot/severity"
+)
+
+// runRules implements `slackbot rules test <file>`.
+func (c *CMD) runRules(args []string) error {
+	if len(args) == 0 || args[0] != "test" {
+		return fmt.Errorf("usage: slackbot rules test [flags] <file|->")
+	}
+
+	fs := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	source := fs.String("source", rules.SourceStdin, "Source of the sample message")
+	level := severity.Info
+	fs.TextVar(&level, "severity", severity.Info, "Severity of the sample message")
+	host := fs.String("host", "", "Hostname to match against, default this host")
+	at := fs.String("at", "", "Time of the message, RFC 3339 or HH:MM today, default now")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: slackbot rules test [flags] <file|->")
+	}
+
+	text, err := readSample(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// Rules never look at secrets
+	opts := c.configOptions()
+	opts.SkipResolve = true
+	conf, err := c.loadConfigWith(opts)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	loc, err := conf.Location()
+	if err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	when, err := parseAt(*at, time.Now().In(loc))
+	if err != nil {
+		return err
+	}
+
+	if *host == "" {
+		if *host, err = c.getHostname(); err != nil {
+			return fmt.Errorf("failed to get hostname: %w", err)
+		}
+	}
+
+	engine, err := conf.RuleEngine()
+	if err != nil {
+		return err
+	}
+	res, err := engine.Evaluate(rules.Event{
+		Text:     normalizeInput(conf, text),
+		Severity: level,
+		Host:     *host,
+		Labels:   conf.Labels,
+		Source:   *source,
+		Time:     when,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, step := range res.Trace {
+		if step.Matched {
+			fmt.Printf("match     %s\n", step.Rule)
+		} else {
+			fmt.Printf("no match  %s (%s)\n", step.Rule, step.Reason)
+		}
+	}
+	fmt.Println()
+	printResult(res, conf.DefaultRoute())
+
+	return nil
+}
+
+func printResult(res rules.Result, defaultRoute []string) {
+	if len(res.Fired) == 0 {
+		fmt.Println("no rule fired")
+	}
+	if res.Drop {
+		fmt.Println("action:   drop")
+		return
+	}
+
+	route := res.Route
+	if len(route) == 0 {
+		route = defaultRoute
+	}
+	fmt.Printf("route:    %s\n", strings.Join(route, ", "))
+	fmt.Printf("severity: %s\n", res.Severity)
+	if res.Template != "" {
+		fmt.Printf("template: %s\n", res.Template)
+	}
+	if len(res.Mentions) > 0 {
+		fmt.Printf("mentions: %s\n", strings.Join(res.Mentions, " "))
+	}
+	fmt.Printf("text:\n%s\n", res.Text)
+}
+
+// readSample reads a sample message from a file, or from stdin for "-".
+func readSample(name string) (string, error) {
+	if name == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(name)
+	return string(data), err
+}
+
+// parseAt parses an RFC 3339 time, or a time of day on the date of now.
+func parseAt(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("15:04", s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want RFC 3339 or HH:MM", s)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location()), nil
+}
@@ -0,0 +1,225 @@
+package slackbot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/history"
+	"github.com/maxkulish/slackbot/schedule"
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
+)
+
+// sendTime returns when to send the message read from stdin, from -at or
+// -in, or the zero time to send it now.
+func (c *CMD) sendTime(conf *config.Config, now time.Time) (time.Time, error) {
+	if c.At == "" && c.In == 0 {
+		return time.Time{}, nil
+	}
+	if c.At != "" && c.In != 0 {
+		return time.Time{}, fmt.Errorf("-at and -in can't be used together")
+	}
+	if c.UpdateKey != "" || c.TTL > 0 {
+		return time.Time{}, fmt.Errorf("scheduled messages can't be used with -update-key or -ttl")
+	}
+
+	loc, err := conf.Location()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timezone: %w", err)
+	}
+	at := now.Add(c.In).In(loc)
+	if c.At != "" {
+		if at, err = schedule.ParseTime(c.At, loc, now); err != nil {
+			return time.Time{}, fmt.Errorf("-at: %w", err)
+		}
+	}
+
+	switch {
+	case !at.After(now):
+		return time.Time{}, fmt.Errorf("%s is in the past", at.Format(config.TimeLayout+" MST"))
+	case at.Sub(now) > schedule.MaxAhead:
+		return time.Time{}, fmt.Errorf("%s is too far ahead; Slack schedules messages up to 120 days ahead", at.Format(config.TimeLayout+" MST"))
+	}
+	return at, nil
+}
+
+// schedule has Slack send msg to t at a time, or queues it for `slackbot
+// flush` if t has no token.
+func (p poster) schedule(t config.Target, at time.Time, msg slack.SlackMessage) error {
+	m := schedule.Message{Destination: t.Name, At: at, Created: time.Now(), Summary: history.Summary(msg.Text)}
+	if t.WebAPI() {
+		api := slack.API{Client: p.client, Token: t.Token}
+		id, err := api.ScheduleMessage(t.Channel, at, msg)
+		if err != nil {
+			return err
+		}
+		m.Channel, m.SlackID = t.Channel, id
+	} else {
+		m.Message = &msg
+	}
+
+	m, err := p.queue.Add(m)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("scheduled %s for %s at %s\n", m.ID, t.Name, at.Format(config.TimeLayout+" MST"))
+	return nil
+}
+
+// runSchedule implements `slackbot schedule <list|cancel>`.
+func (c *CMD) runSchedule(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: slackbot schedule list | cancel <id>")
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	switch args[0] {
+	case "list":
+		return c.runScheduleList(conf, args[1:])
+	case "cancel":
+		return c.runScheduleCancel(conf, args[1:])
+	default:
+		return fmt.Errorf("unknown schedule command %q", args[0])
+	}
+}
+
+func (c *CMD) runScheduleList(conf *config.Config, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: slackbot schedule list")
+	}
+	store, err := state.Open(conf.StateDir)
+	if err != nil {
+		return err
+	}
+	loc, err := conf.Location()
+	if err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+
+	messages, err := (schedule.Store{State: store}).List()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, m := range messages {
+		if !m.Queued() && !m.At.After(now) {
+			// Posted by Slack already
+			continue
+		}
+		by := "slack"
+		if m.Queued() {
+			by = "queued"
+		}
+		fmt.Printf("%s  %s  %-6s  %s  %s\n", m.ID, m.At.In(loc).Format(config.TimeLayout), by, m.Destination, m.Summary)
+	}
+	return nil
+}
+
+func (c *CMD) runScheduleCancel(conf *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: slackbot schedule cancel <id>")
+	}
+	p, err := newPoster(conf)
+	if err != nil {
+		return err
+	} else if p.stateErr != nil {
+		return p.stateErr
+	}
+
+	messages, err := p.queue.List()
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		if m.ID != args[0] {
+			continue
+		}
+
+		if !m.Queued() {
+			d, ok := conf.Destination(m.Destination)
+			if !ok || !d.WebAPI() {
+				return fmt.Errorf("can't cancel %s: destination %s has no token anymore", m.ID, m.Destination)
+			}
+			api := slack.API{Client: p.client, Token: d.Token}
+			if err := api.DeleteScheduledMessage(m.Channel, m.SlackID); err != nil {
+				return err
+			}
+		}
+		if _, _, err := p.queue.Remove(m.ID); err != nil {
+			return err
+		}
+		fmt.Printf("cancelled %s\n", m.ID)
+		return nil
+	}
+	return fmt.Errorf("no scheduled message %q", args[0])
+}
+
+// runFlush implements `slackbot flush`: it sends the queued messages that
+// are due.
+func (c *CMD) runFlush(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: slackbot flush")
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	sent, err := c.flush(conf, time.Now())
+	fmt.Println(plural(sent, "scheduled message") + " sent")
+	return err
+}
+
+// flush sends the queued messages due by now and returns how many it
+// sent. Messages that fail stay queued for the next run. Messages
+// scheduled with Slack are forgotten once they're due.
+func (c *CMD) flush(conf *config.Config, now time.Time) (int, error) {
+	p, err := newPoster(conf)
+	if err != nil {
+		return 0, err
+	} else if p.stateErr != nil {
+		return 0, p.stateErr
+	}
+	return p.flush(conf, now)
+}
+
+// flush sends the queued messages due by now to their destinations, through
+// the webhook or the Web API, whichever they use by now.
+func (p poster) flush(conf *config.Config, now time.Time) (int, error) {
+	due, err := p.queue.Due(now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, m := range due {
+		// Removing it first keeps a concurrent flush from sending it twice
+		if _, claimed, err := p.queue.Remove(m.ID); err != nil {
+			return sent, err
+		} else if !claimed || !m.Queued() {
+			continue
+		}
+
+		targets, err := conf.Targets([]string{m.Destination})
+		if err != nil || m.Message == nil {
+			log.Printf("can't send scheduled message %s: destination %s is gone", m.ID, m.Destination)
+			continue
+		}
+		if err := p.post(targets[0], tracking{}, *m.Message); err != nil {
+			log.Printf("failed to send scheduled message %s to %s: %v", m.ID, m.Destination, err)
+			if _, err := p.queue.Add(m); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
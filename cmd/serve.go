@@ -0,0 +1,149 @@
+package slackbot
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/maxkulish/slackbot/chatops"
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/httpclient"
+	"github.com/maxkulish/slackbot/interactions"
+	"github.com/maxkulish/slackbot/mute"
+	"github.com/maxkulish/slackbot/state"
+)
+
+// tickInterval is how often the daemon runs its periodic jobs.
+const tickInterval = time.Minute
+
+// Paths served by `slackbot serve`, to be set as the app's request URLs
+const (
+	interactionsPath = "/slack/interactions"
+	commandsPath     = "/slack/commands"
+)
+
+// runServe implements `slackbot serve`: it receives button clicks and
+// slash commands from Slack until interrupted, and runs periodic jobs.
+func (c *CMD) runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", "", "Address to listen on, default serve.listen or "+config.DefaultListen)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: slackbot serve [--listen host:port]")
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if conf.Serve.SigningSecret == "" {
+		return fmt.Errorf("serve.signing_secret is required to verify requests from Slack")
+	}
+	if *listen == "" {
+		*listen = conf.Serve.ListenAddr()
+	}
+
+	handler, err := c.serveMux(conf)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Addr: *listen, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go c.tick(ctx, conf)
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", *listen)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serveMux routes the requests Slack sends to the daemon.
+func (c *CMD) serveMux(conf *config.Config) (http.Handler, error) {
+	store, err := state.Open(conf.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpclient.New(conf.HTTP.Options())
+	if err != nil {
+		return nil, fmt.Errorf("http: %w", err)
+	}
+	loc, err := conf.Location()
+	if err != nil {
+		return nil, fmt.Errorf("timezone: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(interactionsPath, &interactions.Handler{
+		SigningSecret: conf.Serve.SigningSecret,
+		Mutes:         mute.Store{State: store},
+		Client:        client,
+		Location:      loc,
+	})
+	mux.Handle(commandsPath, &chatops.Handler{
+		SigningSecret: conf.Serve.SigningSecret,
+		Queries:       c.queries(conf, store),
+		Client:        client,
+	})
+	return mux, nil
+}
+
+// tick runs the periodic jobs until ctx is done. Failures are only logged
+// so that the next run can retry.
+func (c *CMD) tick(ctx context.Context, conf *config.Config) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.releaseEnded(conf, time.Now()); err != nil {
+			log.Printf("failed to send maintenance summary: %v", err)
+		}
+		if sent, err := c.flush(conf, time.Now()); err != nil {
+			log.Printf("failed to send scheduled messages: %v", err)
+		} else if sent > 0 {
+			log.Printf("%s sent", plural(sent, "scheduled message"))
+		}
+		if removed, err := c.collect(conf, time.Now()); err != nil {
+			log.Printf("failed to remove expired messages: %v", err)
+		} else if removed > 0 {
+			log.Printf("%s removed", plural(removed, "expired message"))
+		}
+		if err := c.checkHeartbeats(conf, time.Now()); err != nil {
+			log.Printf("failed to check heartbeats: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -5,20 +5,76 @@
 package slackbot
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/history"
+	"github.com/maxkulish/slackbot/httpclient"
+	"github.com/maxkulish/slackbot/input"
+	"github.com/maxkulish/slackbot/interactions"
 	"github.com/maxkulish/slackbot/localip"
+	"github.com/maxkulish/slackbot/maintenance"
+	"github.com/maxkulish/slackbot/mute"
+	"github.com/maxkulish/slackbot/rules"
+	"github.com/maxkulish/slackbot/schedule"
+	"github.com/maxkulish/slackbot/sent"
+	"github.com/maxkulish/slackbot/severity"
 	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
 	"github.com/maxkulish/slackbot/templates"
+	"github.com/maxkulish/slackbot/termtext"
 )
 
 type CMD struct {
 	ConfigFile string
 	Help       bool
+
+	// Severity is the severity of the message read from stdin.
+	Severity severity.Level
+
+	// DryRun prints the payload instead of sending it, without any
+	// network access.
+	DryRun bool
+
+	// Output is a file to save the payload to.
+	Output string
+
+	// UpdateKey names a message to update instead of posting a new one,
+	// for destinations with a token.
+	UpdateKey string
+
+	// TTL is how long until the message is removed by `slackbot gc`, for
+	// destinations with a token.
+	TTL time.Duration
+
+	// At and In send the message later: at a time in the configured
+	// timezone, or after a delay.
+	At string
+	In time.Duration
+
+	// at is when to send the message, from At or In.
+	at time.Time
+
+	// offline skips the lookups that go over the network, as -dry-run
+	// does, for commands that only show a message.
+	offline bool
+
+	// Overrides holds config values set by flags.
+	Overrides []config.Override
+
+	// Args holds the subcommand and its arguments, if any.
+	Args []string
 }
 
 func (c *CMD) Run() error {
@@ -27,71 +83,630 @@ func (c *CMD) Run() error {
 		return nil
 	}
 
+	if len(c.Args) > 0 {
+		return c.runSubcommand(c.Args[0], c.Args[1:])
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if c.at, err = c.sendTime(conf, time.Now()); err != nil {
+		return err
+	}
+
+	a, err := c.readInputText(conf)
+	if err != nil {
+		return fmt.Errorf("failed to read input text: %w", err)
+	} else if a.Text == "" {
+		return fmt.Errorf("no input text provided")
+	}
+
+	return c.notify(conf, a)
+}
+
+// alert is a message on its way through the rules to Slack.
+type alert struct {
+	Text     string
+	Source   string // one of the rules.Source* names
+	Severity severity.Level
+
+	// Route lists the destinations chosen by the caller, such as a watch
+	// pattern's. Empty means the default destinations. Rules may override it.
+	Route []string
+
+	// Options add content such as a status section.
+	Options []slack.MessageOption
+
+	// File is uploaded after the message, if set.
+	File *slack.File
+}
+
+// notify runs an alert through the rules and maintenance windows, then
+// sends it with the host details.
+func (c *CMD) notify(conf *config.Config, a alert) error {
 	hostname, err := c.getHostname()
 	if err != nil {
 		return fmt.Errorf("failed to get hostname: %w", err)
 	}
 
-	ips, err := localip.GetLocalIPAddr()
+	engine, err := conf.RuleEngine()
 	if err != nil {
-		return fmt.Errorf("failed to get IP addresses: %w", err)
+		return err
+	}
+	now := time.Now()
+	res, err := engine.Evaluate(rules.Event{
+		Text:     a.Text,
+		Severity: a.Severity,
+		Host:     hostname,
+		Labels:   conf.Labels,
+		Source:   a.Source,
+		Time:     now,
+	})
+	if err != nil {
+		return err
+	}
+	if res.Drop {
+		log.Printf("message dropped by rule %s", res.Fired[len(res.Fired)-1])
+		return nil
+	}
+
+	destinations := conf.DefaultRoute()
+	switch {
+	case len(res.Route) > 0:
+		destinations = res.Route
+	case len(a.Route) > 0:
+		destinations = a.Route
 	}
 
-	// Get public IP address
-	publicIP, err := localip.GetPublicIPAddr()
+	// A state directory that can't be used costs mutes, maintenance
+	// windows and history, but never the alert itself
+	store, err := state.Open(conf.StateDir)
 	if err != nil {
-		log.Printf("failed to get public IP address: %v", err)
+		log.Printf("%v; sending without mutes, maintenance windows or history", err)
+	}
+
+	// Repeats of an alert muted from Slack are dropped
+	key := mute.Key(hostname, a.Source, a.Text)
+	if store != nil {
+		if m, muted, err := (mute.Store{State: store}).Active(key, now); err != nil {
+			log.Printf("failed to check mutes: %v", err)
+		} else if muted {
+			log.Printf("message muted until %s", m.Until.Format(time.RFC3339))
+			return nil
+		}
+	}
+
+	track := tracking{Key: c.UpdateKey, TTL: c.TTL, At: c.at}
+	if a.File != nil && !track.At.IsZero() {
+		return fmt.Errorf("binary input can't be scheduled; set input.binary to hexdump to send it as text")
+	}
+
+	var gate *maintenance.Gate
+	if store != nil {
+		if gate, err = c.gate(conf); err != nil {
+			return err
+		}
+	}
+	held := maintenance.Held{
+		Time:     now,
+		Severity: res.Severity,
+		Source:   a.Source,
+		Text:     res.Text,
+		Route:    destinations,
+	}
+
+	switch {
+	case gate == nil:
+	case !track.At.IsZero():
+		// Scheduled messages are meant for later, maintenance or not
+	case c.DryRun:
+		// Show the payload anyway, but leave the state alone
+		window, covered, err := gate.Check(hostname, held)
+		if err != nil {
+			log.Printf("failed to check maintenance windows: %v", err)
+		} else if covered {
+			log.Printf("message would be held back by %s", window)
+		}
+	default:
+		// Summaries of windows that have ended go out before anything new
+		c.releaseHeld(conf, gate, hostname, now)
+
+		admitted, err := gate.Admit(hostname, held)
+		if err != nil {
+			log.Printf("failed to check maintenance windows: %v", err)
+		} else if !admitted {
+			log.Printf("message held back by a maintenance window or quiet hours")
+			return nil
+		}
+	}
+
+	// A broken rotation must not hold up the alert itself
+	mentions, err := conf.Mentions(res.Mentions, res.Severity, now)
+	if err != nil {
+		log.Printf("mentions: %v", err)
+	}
+
+	if a.File != nil {
+		if err := checkUploads(conf, destinations); err != nil {
+			return err
+		}
+	}
+
+	opts := []slack.MessageOption{slack.WithSeverity(res.Severity), slack.WithMentions(mentions...)}
+	if conf.Buttons.Enabled {
+		opts = append(opts, interactions.Buttons(hostname, key, conf.Buttons.MuteFor))
+	}
+	if err := c.deliver(conf, hostname, destinations, track, res.Text, append(opts, a.Options...)...); err != nil {
+		return err
+	}
+
+	if store != nil && !c.DryRun && track.At.IsZero() {
+		entry := history.Entry{Time: now, Severity: res.Severity, Source: a.Source, Summary: history.Summary(res.Text), Route: destinations}
+		if err := (history.Store{State: store}).Record(entry); err != nil {
+			log.Printf("failed to record the alert: %v", err)
+		}
+	}
+
+	if a.File != nil {
+		return c.upload(conf, destinations, *a.File)
+	}
+	return nil
+}
+
+// tracking says how a sent message is remembered, for destinations with
+// a token.
+type tracking struct {
+	// Key names a message to update instead of posting a new one.
+	Key string
+
+	// TTL is how long until the message is removed by `slackbot gc`.
+	TTL time.Duration
+
+	// At schedules the message for later, if set.
+	At time.Time
+}
+
+// deliver wraps text with the host details and sends it to destinations,
+// bypassing rules and maintenance windows.
+func (c *CMD) deliver(conf *config.Config, hostname string, destinations []string, track tracking, text string, extra ...slack.MessageOption) error {
+	ips, err := c.getIPAddrs(conf)
+	if err != nil {
+		return fmt.Errorf("failed to get IP addresses: %w", err)
+	}
+
+	opts := append(c.hostContext(conf), slack.WithLimits(conf.Message.Limits()))
+	if !track.At.IsZero() {
+		opts = append(opts, slack.WithTime(track.At))
+	}
+	msg := slack.PrepareMessage(hostname, text, ips, append(opts, extra...)...)
+
+	var targets []config.Target
+	if len(conf.DestinationNames()) == 0 {
+		err = fmt.Errorf("no webhook configured; see `slackbot config show --origin`")
 	} else {
-		ips = append(ips, publicIP)
+		targets, err = conf.Targets(destinations)
+	}
+	if err != nil && !c.DryRun {
+		return err
+	} else if err != nil {
+		// Show the payload anyway, which is what a dry run is for
+		log.Printf("warning: %v", err)
 	}
 
-	inputText, err := c.readInputText()
+	return c.send(conf, targets, track, msg)
+}
+
+// send delivers msg to every target, attempting all of them even if some
+// fail. With -output the payload is saved first; with -dry-run it's
+// printed instead of sent.
+func (c *CMD) send(conf *config.Config, targets []config.Target, track tracking, msg slack.SlackMessage) error {
+	if c.Output != "" || c.DryRun {
+		payload, err := renderPayload(targets, msg)
+		if err != nil {
+			return err
+		}
+		if err := c.writePayload(payload, track); err != nil || c.DryRun {
+			return err
+		}
+	}
+
+	p, err := newPoster(conf)
 	if err != nil {
-		return fmt.Errorf("failed to read input text: %w", err)
-	} else if inputText == "" {
-		return fmt.Errorf("no input text provided")
+		return err
+	}
+
+	var errs []error
+	for _, t := range targets {
+		if err := p.post(t, track, msg); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send Slack notification to %s: %w", t.Name, err))
+		}
 	}
+	return errors.Join(errs...)
+}
+
+// poster posts messages to destinations through their webhook or the Web API.
+type poster struct {
+	client *http.Client
+	sent   sent.Store
+	queue  schedule.Store
 
-	msg := slack.PrepareMessage(hostname, inputText, ips)
+	// stateErr is why the state directory can't be used, if it can't.
+	// Only tracked and scheduled messages need it.
+	stateErr error
+}
+
+func newPoster(conf *config.Config) (poster, error) {
+	client, err := httpclient.New(conf.HTTP.Options())
 	if err != nil {
-		return fmt.Errorf("failed to prepare message: %w", err)
+		return poster{}, fmt.Errorf("http: %w", err)
+	}
+	p := poster{client: client}
+	if store, err := state.Open(conf.StateDir); err != nil {
+		p.stateErr = err
+	} else {
+		p.sent, p.queue = sent.Store{State: store}, schedule.Store{State: store}
 	}
+	return p, nil
+}
 
-	conf, err := config.NewConfig(c.ConfigFile)
+// post sends msg to t. With a key and the Web API, the message posted with
+// the same key is updated, if there is one.
+func (p poster) post(t config.Target, track tracking, msg slack.SlackMessage) error {
+	if !track.At.IsZero() {
+		if p.stateErr != nil {
+			return p.stateErr
+		}
+		return p.schedule(t, track.At, msg)
+	}
+	if p.stateErr != nil && (track.Key != "" || track.TTL > 0) {
+		log.Printf("%v; posting without -update-key or -ttl", p.stateErr)
+		track = tracking{}
+	}
+	if !t.WebAPI() {
+		if track.Key != "" {
+			log.Printf("%s has no token, so the message is posted anew instead of updated", t.Name)
+		}
+		if track.TTL > 0 {
+			log.Printf("%s has no token, so the message won't be removed", t.Name)
+		}
+		return slack.SendSlackNotification(p.client, t.WebHook, msg)
+	}
+
+	api := slack.API{Client: p.client, Token: t.Token}
+	now := time.Now()
+	m := sent.Message{Key: track.Key, Destination: t.Name, Channel: t.Channel, Time: now, Summary: history.Summary(msg.Text)}
+	if track.TTL > 0 {
+		m.Expires = now.Add(track.TTL)
+	}
+
+	if prev, ok, err := p.sent.Lookup(track.Key, t.Name); err != nil {
+		log.Printf("failed to look up the message with key %q, posting it anew: %v", track.Key, err)
+	} else if ok && prev.Channel == t.Channel {
+		err := api.UpdateMessage(prev.Channel, prev.TS, msg)
+		if err == nil {
+			m.TS = prev.TS
+			if m.Expires.IsZero() && now.Before(prev.Expires) {
+				// An update without -ttl keeps a pending expiry, but one
+				// that passed would remove the message it just updated
+				m.Expires = prev.Expires
+			}
+			return p.record(m)
+		} else if !slack.IsMessageGone(err) {
+			return err
+		}
+		// Deleted in the meantime: post it again
+	}
+
+	ts, err := api.PostMessage(t.Channel, msg)
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return err
+	}
+	if m.Key == "" && m.Expires.IsZero() {
+		return nil
 	}
+	m.TS = ts
+	return p.record(m)
+}
 
-	if err = slack.SendSlackNotification(conf.WebHook, msg); err != nil {
-		return fmt.Errorf("failed to send Slack notification: %w", err)
+// record remembers a message that was sent. Failing to is only logged,
+// since the message went out.
+func (p poster) record(m sent.Message) error {
+	if err := p.sent.Record(m); err != nil {
+		log.Printf("failed to record the message for later updates: %v", err)
 	}
+	return nil
+}
 
+// checkUploads fails unless every destination can take a file upload.
+func checkUploads(conf *config.Config, destinations []string) error {
+	targets, err := conf.Targets(destinations)
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		if t.Token == "" {
+			return fmt.Errorf("destination %s can't take files: set its token and channel", t.Name)
+		}
+	}
 	return nil
 }
 
+// upload shares f in the channel of every destination.
+func (c *CMD) upload(conf *config.Config, destinations []string, f slack.File) error {
+	targets, err := conf.Targets(destinations)
+	if err != nil {
+		return err
+	}
+
+	if c.DryRun {
+		for _, t := range targets {
+			log.Printf("would attach %s (%s) in %s", f.Name, input.FormatSize(int64(len(f.Data))), t.Name)
+		}
+		return nil
+	}
+
+	client, err := httpclient.New(conf.HTTP.Options())
+	if err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+
+	var errs []error
+	for _, t := range targets {
+		api := slack.API{Client: client, Token: t.Token}
+		if err := api.UploadFile(t.Channel, f, ""); err != nil {
+			errs = append(errs, fmt.Errorf("failed to upload %s to %s: %w", f.Name, t.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writePayload saves payload to -output, if set, and with -dry-run prints
+// it along with what would happen to the message.
+func (c *CMD) writePayload(payload []byte, track tracking) error {
+	if c.Output != "" {
+		if err := os.WriteFile(c.Output, payload, 0o644); err != nil {
+			return err
+		}
+	}
+	if !c.DryRun {
+		return nil
+	}
+
+	if track.Key != "" {
+		log.Printf("would update the message posted with key %q, where there is one", track.Key)
+	}
+	if !track.At.IsZero() {
+		log.Printf("would schedule the message for %s", track.At.Format(config.TimeLayout+" MST"))
+	}
+	if track.TTL > 0 {
+		log.Printf("would remove the message after %s", track.TTL)
+	}
+	_, err := os.Stdout.Write(payload)
+	return err
+}
+
+// renderPayload returns the JSON sent to a single target, or an object of
+// payloads keyed by destination name when there are several.
+func renderPayload(targets []config.Target, msg slack.SlackMessage) ([]byte, error) {
+	var v any = msg
+	if len(targets) > 1 {
+		byName := map[string]slack.SlackMessage{}
+		for _, t := range targets {
+			byName[t.Name] = msg
+		}
+		v = byName
+	}
+	return encodePayload(v)
+}
+
+// encodePayload formats a payload, or an object of payloads, as JSON.
+func encodePayload(v any) ([]byte, error) {
+
+	// Keep mentions such as <@U123> readable
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *CMD) runSubcommand(name string, args []string) error {
+	switch name {
+	case "config":
+		return c.runConfig(args)
+	case "test":
+		return c.runTest(args)
+	case "systemd-failed":
+		return c.runSystemdFailed(args)
+	case "watch":
+		return c.runWatch(args)
+	case "rules":
+		return c.runRules(args)
+	case "oncall":
+		return c.runOnCall(args)
+	case "maintenance":
+		return c.runMaintenance(args)
+	case "preview":
+		return c.runPreview(args)
+	case "serve":
+		return c.runServe(args)
+	case "gc":
+		return c.runGC(args)
+	case "schedule":
+		return c.runSchedule(args)
+	case "flush":
+		return c.runFlush(args)
+	case "heartbeat":
+		return c.runHeartbeat(args)
+	default:
+		return fmt.Errorf("unknown command %q, see -help", name)
+	}
+}
+
+// loadConfig merges every config layer and reports loading warnings.
+func (c *CMD) loadConfig() (*config.Config, error) {
+	return c.loadConfigWith(c.configOptions())
+}
+
+func (c *CMD) configOptions() config.Options {
+	opts := config.DefaultOptions()
+	opts.File = c.ConfigFile
+	opts.Flags = c.Overrides
+	return opts
+}
+
+func (c *CMD) loadConfigWith(opts config.Options) (*config.Config, error) {
+	conf, err := config.Load(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range conf.Warnings {
+		log.Printf("warning: %s", w)
+	}
+	if c.DryRun {
+		for _, err := range conf.Validate() {
+			log.Printf("warning: %v", err)
+		}
+	}
+
+	return conf, nil
+}
+
 func (c *CMD) getHostname() (string, error) {
 	return os.Hostname()
 }
 
-func (c *CMD) readInputText() (string, error) {
+// getIPAddrs returns the local addresses followed by the public ones, if
+// they can be discovered.
+func (c *CMD) getIPAddrs(conf *config.Config) ([]localip.IPAddrInfo, error) {
+	ips, err := localip.GetLocalIPAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err = conf.Interfaces.Filter().Apply(ips)
+	if err != nil {
+		return nil, fmt.Errorf("interfaces: %w", err)
+	}
+
+	if conf.PublicIP.Disabled || c.noNetwork() {
+		return ips, nil
+	}
+
+	transport, err := conf.HTTP.Options().Transport()
+	if err != nil {
+		return nil, fmt.Errorf("http: %w", err)
+	}
+
+	// Get public IP addresses
+	publicIPs, err := localip.DiscoverPublicIPs(context.Background(), localip.PublicIPOptions{
+		Providers:  conf.PublicIP.Providers,
+		Families:   conf.PublicIP.Families,
+		Timeout:    conf.PublicIP.Timeout,
+		CacheTTL:   conf.PublicIP.CacheTTL,
+		FailureTTL: conf.PublicIP.FailureTTL,
+		Cache:      cacheStore(conf),
+		Transport:  transport,
+	})
+	if err != nil {
+		log.Printf("failed to get public IP address: %v", err)
+	}
+
+	return append(ips, publicIPs...), nil
+}
+
+// noNetwork reports whether lookups over the network are skipped.
+func (c *CMD) noNetwork() bool {
+	return c.DryRun || c.offline
+}
+
+// cacheStore opens the state directory for caching lookups. Caches are
+// optional, so a state directory that can't be used only makes lookups
+// slower.
+func cacheStore(conf *config.Config) *state.Store {
+	store, err := state.Open(conf.StateDir)
+	if err != nil {
+		log.Printf("%v; looking up without a cache", err)
+		return nil
+	}
+	return store
+}
+
+// readInputText reads the message from stdin, unless it's a terminal.
+func (c *CMD) readInputText(conf *config.Config) (alert, error) {
 	fileInfo, err := os.Stdin.Stat()
 	if err != nil {
-		return "", fmt.Errorf("failed to stat stdin: %w", err)
+		return alert{}, fmt.Errorf("failed to stat stdin: %w", err)
 	}
 
 	// Check if data is available on stdin (e.g., piped input or redirect)
 	if fileInfo.Mode()&os.ModeCharDevice == 0 {
-		var inputText string
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			inputText += "\n" + scanner.Text()
-		}
-		if err := scanner.Err(); err != nil {
-			return "", fmt.Errorf("error reading stdin: %w", err)
-		}
-		return inputText, nil
+		return c.readText(conf, os.Stdin)
 	}
 
 	// No data available on stdin; don't block waiting for input
-	return "", nil
+	return alert{}, nil
+}
+
+// hexDumpBytes is how much of binary input is shown as a hex dump.
+const hexDumpBytes = 256
+
+// readText reads a message, normalized as a terminal would show it and
+// starting with a newline. Binary input is rejected, shown as a hex dump
+// or attached as a file, as configured.
+func (c *CMD) readText(conf *config.Config, r io.Reader) (alert, error) {
+	a := alert{Source: rules.SourceStdin, Severity: c.Severity}
+
+	in, err := input.Read(r, int64(conf.Input.MaxSize))
+	if errors.Is(err, input.ErrTooLarge) && conf.Input.BinaryPolicy() == config.BinaryHexDump {
+		// The start is all a hex dump needs
+	} else if err != nil {
+		return alert{}, fmt.Errorf("error reading stdin: %w", err)
+	}
+
+	if in.Binary {
+		desc := fmt.Sprintf("binary input, %s, %s", in.ContentType, input.FormatSize(in.Size))
+		switch conf.Input.BinaryPolicy() {
+		case config.BinaryHexDump:
+			a.Text = "\n" + desc + "\n" + input.HexDump(in.Data, hexDumpBytes)
+		case config.BinaryAttach:
+			a.File = &slack.File{Name: "stdin" + extension(in.ContentType), ContentType: in.ContentType, Data: in.Data}
+			a.Text = "\n" + desc + ", attached as " + a.File.Name
+		default:
+			return alert{}, fmt.Errorf("%s; set input.binary to hexdump or attach to send it", desc)
+		}
+		return a, nil
+	}
+
+	text := normalizeInput(conf, in.Text)
+	if in.Skipped > 0 {
+		text = fmt.Sprintf("… first %s of input skipped …\n", input.FormatSize(in.Skipped)) + text
+	}
+	if text != "" {
+		a.Text = "\n" + strings.TrimSuffix(text, "\n")
+	}
+	return a, nil
+}
+
+// normalizeInput collapses progress updates and removes escape sequences
+// from terminal output, unless the config asks for raw input.
+func normalizeInput(conf *config.Config, text string) string {
+	if conf.Input.Raw {
+		return text
+	}
+	return termtext.Normalize(text, conf.Input.TermText())
+}
+
+// extension returns the file name extension for a MIME type, or .bin.
+func extension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
 }
@@ -0,0 +1,376 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/rules"
+	"github.com/maxkulish/slackbot/schedule"
+	"github.com/maxkulish/slackbot/sent"
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
+)
+
+// fakeSlack serves webhooks below /hook, Web API methods below /api and a
+// public IP provider at /ip, and records every request.
+type fakeSlack struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []request
+}
+
+type request struct {
+	Path string
+	Body string
+}
+
+func newFakeSlack(t *testing.T) *fakeSlack {
+	f := &fakeSlack{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.requests = append(f.requests, request{Path: r.URL.Path, Body: string(body)})
+		f.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/ip":
+			io.WriteString(w, "203.0.113.7\n")
+		case "/api/chat.postMessage":
+			io.WriteString(w, `{"ok":true,"channel":"C1","ts":"1700000000.000100"}`)
+		case "/api/chat.scheduleMessage":
+			io.WriteString(w, `{"ok":true,"scheduled_message_id":"Q1"}`)
+		default:
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				io.WriteString(w, `{"ok":true}`)
+			} else {
+				io.WriteString(w, "ok")
+			}
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// paths returns the paths requested so far that start with prefix.
+func (f *fakeSlack) paths(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var paths []string
+	for _, r := range f.requests {
+		if strings.HasPrefix(r.Path, prefix) {
+			paths = append(paths, r.Path)
+		}
+	}
+	return paths
+}
+
+func (f *fakeSlack) count() int {
+	return len(f.paths("/"))
+}
+
+// client returns a client that sends every request, such as those to
+// slack.com, to the fake server.
+func (f *fakeSlack) client() *http.Client {
+	to, _ := url.Parse(f.URL)
+	return &http.Client{Transport: redirect{to}}
+}
+
+type redirect struct{ to *url.URL }
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host, req.Host = r.to.Scheme, r.to.Host, ""
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// writeConfig writes a config with a webhook and a public IP provider on
+// f, plus extra, and returns its path.
+func writeConfig(t *testing.T, f *fakeSlack, extra string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	data := `webhook: "` + f.URL + `/hook/default"
+state_dir: "` + filepath.Join(dir, "state") + `"
+public_ip:
+  providers: ["` + f.URL + `/ip"]
+  families: [ipv4]
+container: {disabled: true}
+` + extra
+	path := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadTestConfig loads only the config file at path, without the system
+// and user layers or the environment.
+func loadTestConfig(t *testing.T, path string) *config.Config {
+	t.Helper()
+
+	conf, err := config.Load(config.Options{File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+// isolate keeps loadConfig away from the user's config and environment.
+func isolate(t *testing.T) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, config.EnvPrefix) {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+}
+
+// captureStdout returns what f writes to stdout.
+func captureStdout(t *testing.T, f func() error) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+
+	err = f()
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return <-out
+}
+
+func TestNotifyDryRunMakesNoRequests(t *testing.T) {
+	f := newFakeSlack(t)
+	conf := loadTestConfig(t, writeConfig(t, f, ""))
+	c := &CMD{DryRun: true, UpdateKey: "disk", TTL: time.Hour}
+
+	out := captureStdout(t, func() error {
+		return c.notify(conf, alert{Text: "\ndisk full", Source: rules.SourceStdin})
+	})
+
+	if n := f.count(); n != 0 {
+		t.Errorf("dry run made %d requests: %q", n, f.paths("/"))
+	}
+	var msg slack.SlackMessage
+	if err := json.Unmarshal([]byte(out), &msg); err != nil {
+		t.Fatalf("dry run printed %q: %v", out, err)
+	}
+	if !strings.Contains(msg.Text, "disk full") {
+		t.Errorf("payload text = %q, want the message", msg.Text)
+	}
+}
+
+func TestNotifyOutput(t *testing.T) {
+	f := newFakeSlack(t)
+	conf := loadTestConfig(t, writeConfig(t, f, ""))
+	output := filepath.Join(t.TempDir(), "payload.json")
+	c := &CMD{Output: output}
+
+	if err := c.notify(conf, alert{Text: "\ndisk full", Source: rules.SourceStdin}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.paths("/hook"); len(got) != 1 || got[0] != "/hook/default" {
+		t.Errorf("webhook requests = %q, want one to the default destination", got)
+	}
+	if got := f.paths("/ip"); len(got) != 1 {
+		t.Errorf("public IP requests = %q, want one", got)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved, posted slack.SlackMessage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(f.requests[len(f.requests)-1].Body), &posted); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Text != posted.Text || !strings.Contains(saved.Text, "disk full") {
+		t.Errorf("saved text %q, posted %q, want the same message", saved.Text, posted.Text)
+	}
+	if !strings.Contains(string(data), "203.0.113.7") {
+		t.Errorf("payload %s doesn't show the public IP", data)
+	}
+}
+
+const opsDestination = `destinations:
+  ops:
+    webhook: "%s/hook/ops"
+`
+
+func TestRunTest(t *testing.T) {
+	isolate(t)
+	f := newFakeSlack(t)
+	c := &CMD{ConfigFile: writeConfig(t, f, strings.Replace(opsDestination, "%s", f.URL, 1))}
+
+	out := captureStdout(t, func() error { return c.runTest(nil) })
+
+	if got := f.paths("/hook"); strings.Join(got, " ") != "/hook/default /hook/ops" {
+		t.Errorf("webhook requests = %q, want one to each destination", got)
+	}
+	if out != "ok    default\nok    ops\n" {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestRunTestDryRunMakesNoRequests(t *testing.T) {
+	isolate(t)
+	f := newFakeSlack(t)
+	c := &CMD{ConfigFile: writeConfig(t, f, strings.Replace(opsDestination, "%s", f.URL, 1)), DryRun: true}
+
+	out := captureStdout(t, func() error { return c.runTest(nil) })
+
+	if n := f.count(); n != 0 {
+		t.Errorf("dry run made %d requests: %q", n, f.paths("/"))
+	}
+	var byName map[string]slack.SlackMessage
+	if err := json.Unmarshal([]byte(out), &byName); err != nil {
+		t.Fatalf("dry run printed %q: %v", out, err)
+	}
+	if len(byName) != 2 || !strings.Contains(byName["ops"].Text, `"ops" destination`) {
+		t.Errorf("payloads = %+v, want a test message for each destination", byName)
+	}
+}
+
+// webAPIPoster returns a poster that sends everything to f, with its
+// state in a temporary directory.
+func webAPIPoster(t *testing.T, f *fakeSlack) poster {
+	t.Helper()
+
+	store, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return poster{client: f.client(), sent: sent.Store{State: store}, queue: schedule.Store{State: store}}
+}
+
+func TestPostUpdateKeyAndTTL(t *testing.T) {
+	f := newFakeSlack(t)
+	p := webAPIPoster(t, f)
+	target := config.Target{Name: "ops", Destination: config.Destination{Token: "xoxb-test", Channel: "C1"}}
+	msg := slack.SlackMessage{Text: "deploy started"}
+
+	if err := p.post(target, tracking{Key: "deploy", TTL: time.Hour}, msg); err != nil {
+		t.Fatal(err)
+	}
+	first, ok, err := p.sent.Lookup("deploy", "ops")
+	if err != nil || !ok || first.TS != "1700000000.000100" || first.Expires.IsZero() {
+		t.Fatalf("Lookup() = %+v, %v, %v, want the posted message with an expiry", first, ok, err)
+	}
+
+	// An update without -ttl keeps the pending expiry
+	if err := p.post(target, tracking{Key: "deploy"}, msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.paths("/api"); strings.Join(got, " ") != "/api/chat.postMessage /api/chat.update" {
+		t.Errorf("API calls = %q, want a post and then an update", got)
+	}
+	if m, _, _ := p.sent.Lookup("deploy", "ops"); m.TS != first.TS || !m.Expires.Equal(first.Expires) {
+		t.Errorf("after the update = %+v, want the same message and expiry", m)
+	}
+}
+
+func TestPostSchedule(t *testing.T) {
+	f := newFakeSlack(t)
+	p := webAPIPoster(t, f)
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	msg := slack.SlackMessage{Text: "standup"}
+
+	captureStdout(t, func() error {
+		if err := p.post(config.Target{Name: "default", Destination: config.Destination{WebHook: f.URL + "/hook/default"}}, tracking{At: at}, msg); err != nil {
+			return err
+		}
+		return p.post(config.Target{Name: "ops", Destination: config.Destination{Token: "xoxb-test", Channel: "C1"}}, tracking{At: at}, msg)
+	})
+
+	if got := f.paths("/"); strings.Join(got, " ") != "/api/chat.scheduleMessage" {
+		t.Errorf("requests = %q, want only the Slack scheduled message", got)
+	}
+	messages, err := p.queue.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("scheduled = %+v, want 2 messages", messages)
+	}
+	for _, m := range messages {
+		switch {
+		case !m.At.Equal(at):
+			t.Errorf("%s scheduled at %s, want %s", m.Destination, m.At, at)
+		case m.Destination == "default" && (!m.Queued() || m.Message == nil):
+			t.Errorf("webhook message = %+v, want it queued with its content", m)
+		case m.Destination == "ops" && m.SlackID != "Q1":
+			t.Errorf("Web API message = %+v, want Slack's ID", m)
+		}
+	}
+}
+
+func TestGetIPAddrsOffline(t *testing.T) {
+	f := newFakeSlack(t)
+	conf := loadTestConfig(t, writeConfig(t, f, ""))
+	c := &CMD{offline: true}
+
+	if _, err := c.getIPAddrs(conf); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.count(); n != 0 {
+		t.Errorf("offline lookup made %d requests: %q", n, f.paths("/"))
+	}
+}
+
+func TestFlushThroughWebAPI(t *testing.T) {
+	f := newFakeSlack(t)
+	p := webAPIPoster(t, f)
+	now := time.Now()
+
+	// Queued for the webhook, before the destination got a token
+	msg := slack.SlackMessage{Text: "standup"}
+	if _, err := p.queue.Add(schedule.Message{Destination: "ops", At: now.Add(-time.Minute), Message: &msg}); err != nil {
+		t.Fatal(err)
+	}
+	conf := loadTestConfig(t, writeConfig(t, f, `destinations:
+  ops: {token: "xoxb-test", channel: C1}
+`))
+
+	sent, err := p.flush(conf, now)
+	if err != nil || sent != 1 {
+		t.Fatalf("flush() = %d, %v; want 1 sent", sent, err)
+	}
+	if got := f.paths("/"); strings.Join(got, " ") != "/api/chat.postMessage" {
+		t.Errorf("requests = %q, want the message posted with the Web API", got)
+	}
+	if left, _ := p.queue.List(); len(left) != 0 {
+		t.Errorf("queue = %+v, want it empty", left)
+	}
+}
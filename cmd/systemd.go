@@ -0,0 +1,90 @@
+package slackbot
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/rules"
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/systemd"
+)
+
+// systemdTimeout bounds the systemctl and journalctl calls.
+const systemdTimeout = 30 * time.Second
+
+// runSystemdFailed implements `slackbot systemd-failed <unit>`, meant to be
+// started from OnFailure= through the slackbot-notify@.service template.
+func (c *CMD) runSystemdFailed(args []string) error {
+	fs := flag.NewFlagSet("systemd-failed", flag.ContinueOnError)
+	lines := fs.Int("lines", 20, "Number of journal lines to include")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: slackbot systemd-failed [--lines N] <unit>")
+	}
+	unit := fs.Arg(0)
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), systemdTimeout)
+	defer cancel()
+
+	status, err := systemd.Status(ctx, unit)
+	if err != nil {
+		return fmt.Errorf("failed to get status of %s: %w", unit, err)
+	}
+
+	// The alert is still worth sending without the journal
+	entries, err := systemd.Journal(ctx, unit, status.InvocationID, *lines)
+	if err != nil {
+		log.Printf("failed to read journal of %s: %v", unit, err)
+	}
+
+	return c.notify(conf, alert{
+		Text:     formatJournal(entries),
+		Source:   rules.SourceSystemd,
+		Severity: severity.Error,
+		Options:  []slack.MessageOption{unitSection(status)},
+	})
+}
+
+// unitSection summarizes the unit's state above its journal.
+func unitSection(s systemd.UnitStatus) slack.MessageOption {
+	title := fmt.Sprintf(":x: *%s* failed", s.Unit)
+	if s.Description != "" {
+		title = fmt.Sprintf(":x: *%s* (%s) failed", s.Unit, s.Description)
+	}
+
+	fields := []string{fmt.Sprintf("*State*\n%s (%s)", s.ActiveState, s.SubState)}
+	if s.Result != "" {
+		fields = append(fields, "*Result*\n"+s.Result)
+	}
+	if exit := s.ExitDescription(); exit != "" {
+		fields = append(fields, "*Main process*\n"+exit)
+	}
+	fields = append(fields, "*Restarts*\n"+strconv.Itoa(s.Restarts))
+
+	return slack.WithSection(title, fields...)
+}
+
+func formatJournal(entries []systemd.JournalEntry) string {
+	if len(entries) == 0 {
+		return "\n(no journal entries)"
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n%s %s", e.Time.Format("Jan 02 15:04:05"), e.Message)
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package slackbot
+
+import (
+	"fmt"
+
+	"github.com/maxkulish/slackbot/slack"
+)
+
+const testMessage = `:test_tube: *slackbot test message*
+This message was sent by ` + "`slackbot test`" + ` to check the %q destination. No action is needed.`
+
+// runTest implements `slackbot test`: it sends a labelled test message to
+// every configured destination and reports the result for each one.
+func (c *CMD) runTest(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: slackbot test")
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	hostname, err := c.getHostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	ips, err := c.getIPAddrs(conf)
+	if err != nil {
+		return fmt.Errorf("failed to get IP addresses: %w", err)
+	}
+
+	targets, err := conf.Targets(conf.DestinationNames())
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no destinations configured")
+	}
+
+	hostContext := c.hostContext(conf)
+	msgs := map[string]slack.SlackMessage{}
+	for _, t := range targets {
+		msgs[t.Name] = slack.PrepareMessage(hostname, fmt.Sprintf(testMessage, t.Name), ips, hostContext...)
+	}
+
+	if c.Output != "" || c.DryRun {
+		var v any = msgs
+		if len(targets) == 1 {
+			v = msgs[targets[0].Name]
+		}
+		payload, err := encodePayload(v)
+		if err != nil {
+			return err
+		}
+		if err := c.writePayload(payload, tracking{}); err != nil || c.DryRun {
+			return err
+		}
+	}
+
+	p, err := newPoster(conf)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, t := range targets {
+		if err := p.post(t, tracking{}, msgs[t.Name]); err != nil {
+			fmt.Printf("FAIL  %s: %v\n", t.Name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("ok    %s\n", t.Name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d destinations failed", failed, len(targets))
+	}
+
+	return nil
+}
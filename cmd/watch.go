@@ -0,0 +1,120 @@
+package slackbot
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/rules"
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
+	"github.com/maxkulish/slackbot/watch"
+)
+
+// runWatch implements `slackbot watch <file>...`: it follows each file and
+// sends the lines that match the configured patterns until interrupted.
+func (c *CMD) runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fromStart := fs.Bool("from-start", false, "Read files seen for the first time from the beginning")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: slackbot watch [--from-start] <file>...")
+	}
+
+	conf, err := c.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	store, err := state.Open(conf.StateDir)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, fs.NArg())
+	for _, path := range fs.Args() {
+		w, err := c.newWatcher(conf, store, path)
+		if err != nil {
+			return err
+		}
+		w.FromStart = *fromStart
+
+		go func() {
+			errs <- w.Run(ctx, func(m watch.Match) error {
+				// A failed delivery shouldn't stop the watcher
+				if err := c.sendMatch(conf, m); err != nil {
+					log.Printf("failed to send match from %s: %v", m.Path, err)
+				}
+				return nil
+			})
+		}()
+	}
+
+	for range fs.Args() {
+		if err := <-errs; err != nil {
+			stop()
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CMD) newWatcher(conf *config.Config, store *state.Store, path string) (*watch.Watcher, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []watch.Pattern
+	for _, p := range conf.Watch.PatternsFor(abs) {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("watch pattern %q: %w", p.Regex, err)
+		}
+		patterns = append(patterns, watch.Pattern{Regex: re, Severity: p.Severity, Destination: p.Destination})
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no watch patterns apply to %s", abs)
+	}
+
+	return &watch.Watcher{
+		Path:         abs,
+		Patterns:     patterns,
+		ContextLines: conf.Watch.Context(),
+		PollInterval: conf.Watch.PollInterval,
+		Store:        store,
+	}, nil
+}
+
+func (c *CMD) sendMatch(conf *config.Config, m watch.Match) error {
+	var route []string
+	if m.Destination != "" {
+		route = []string{m.Destination}
+	}
+
+	title := fmt.Sprintf("Matched in `%s`", m.Path)
+	if m.Matched > 1 {
+		title = fmt.Sprintf("%d matching lines in `%s`", m.Matched, m.Path)
+	}
+
+	return c.notify(conf, alert{
+		Text:     "\n" + strings.Join(m.Lines, "\n"),
+		Source:   rules.SourceWatch,
+		Severity: m.Severity,
+		Route:    route,
+		Options:  []slack.MessageOption{slack.WithSection(title)},
+	})
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maxkulish/slackbot/cloudmeta"
+)
+
+// CloudConfig enables instance metadata enrichment. Probing is off by
+// default so that hosts outside a cloud don't send link-local requests.
+type CloudConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Providers  []string      `yaml:"providers"`   // aws, gcp, azure, digitalocean; default all
+	Timeout    time.Duration `yaml:"timeout"`     // e.g. 500ms
+	CacheTTL   time.Duration `yaml:"cache_ttl"`   // e.g. 24h
+	FailureTTL time.Duration `yaml:"failure_ttl"` // e.g. 10m
+}
+
+func (c CloudConfig) validate(fail func(path string, err error)) {
+	for i, p := range c.Providers {
+		if err := cloudmeta.ValidateProvider(p); err != nil {
+			fail("cloud.providers", fmt.Errorf("item %d: %w", i+1, err))
+		}
+	}
+}
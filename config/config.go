@@ -2,28 +2,233 @@
 package config
 
 import (
-	"os"
+	"bytes"
+	"fmt"
+	"strings"
 
+	"github.com/maxkulish/slackbot/rules"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
+	// WebHook is the implicit destination named "default".
 	WebHook string `yaml:"webhook"`
+
+	// Destinations are additional named webhooks.
+	Destinations map[string]*Destination `yaml:"destinations"`
+
+	// DefaultDestinations receive messages that aren't routed anywhere else.
+	// Defaults to the "default" destination.
+	DefaultDestinations []string `yaml:"default_destinations"`
+
+	// StateDir holds caches and queues. Defaults to /var/lib/slackbot for
+	// root and ~/.local/state/slackbot for other users.
+	StateDir string `yaml:"state_dir"`
+
+	PublicIP   PublicIPConfig   `yaml:"public_ip"`
+	Interfaces InterfacesConfig `yaml:"interfaces"`
+	Cloud      CloudConfig      `yaml:"cloud"`
+	Container  ContainerConfig  `yaml:"container"`
+
+	Watch WatchConfig `yaml:"watch"`
+
+	Input   InputConfig   `yaml:"input"`
+	Message MessageConfig `yaml:"message"`
+	Expire  ExpireConfig  `yaml:"expire"`
+	HTTP    HTTPConfig    `yaml:"http"`
+
+	// Labels describe this host to rules, e.g. env: prod.
+	Labels map[string]string `yaml:"labels"`
+
+	// Rules are evaluated in order for every message, see package rules.
+	Rules []rules.Rule `yaml:"rules"`
+
+	// Templates are text/template message bodies that rules can select by name.
+	Templates map[string]string `yaml:"templates"`
+
+	// OnCall maps users to Slack IDs and defines who is on call.
+	OnCall OnCallConfig `yaml:"oncall"`
+
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+
+	Buttons ButtonsConfig `yaml:"buttons"`
+	Serve   ServeConfig   `yaml:"serve"`
+
+	// Timezone is used for time-of-day rules and dates, e.g. Europe/Berlin. Defaults to local time.
+	Timezone string `yaml:"timezone"`
+
+	// HostFacts adds OS, uptime, load, memory and disk usage to messages.
+	HostFacts bool `yaml:"host_facts"`
+
+	// Sources lists the layers that contributed to the config, in order.
+	Sources []string `yaml:"-"`
+
+	// Warnings collects non-fatal problems found while loading,
+	// such as secret files with permissions that are too open.
+	Warnings []string `yaml:"-"`
+
+	origins map[string]Origin
+	raw     *yaml.Node
 }
 
-// NewConfig loads config from config_env.yml file
+// NewConfig loads config from a single file. Unlike Load, the file must exist.
 func NewConfig(cf string) (*Config, error) {
-	c := &Config{}
+	return Load(Options{File: cf})
+}
 
-	confFile, err := os.ReadFile(cf)
-	if err != nil {
-		return nil, err
+// Origin returns where the value at the dotted key path was set.
+func (c *Config) Origin(path string) (Origin, bool) {
+	o, ok := c.origins[path]
+	return o, ok
+}
+
+// secretField is a config value that may hold a secret.
+type secretField struct {
+	path  string
+	value *string
+}
+
+func (c *Config) secretFields() []secretField {
+	fields := []secretField{
+		{"webhook", &c.WebHook},
+	}
+
+	for _, name := range sortedKeys(c.Destinations) {
+		if d := c.Destinations[name]; d != nil {
+			fields = append(fields, secretField{"destinations." + name + ".webhook", &d.WebHook})
+			if d.Token != "" {
+				fields = append(fields, secretField{"destinations." + name + ".token", &d.Token})
+			}
+		}
+	}
+
+	if c.Serve.SigningSecret != "" {
+		fields = append(fields, secretField{"serve.signing_secret", &c.Serve.SigningSecret})
+	}
+
+	if c.HTTP.proxyHasSecret() {
+		fields = append(fields, secretField{"http.proxy", &c.HTTP.Proxy})
+	}
+
+	return fields
+}
+
+// checkLiteralSecrets warns about config files that hold a secret literally,
+// since such a file is itself a secret.
+func (c *Config) checkLiteralSecrets() error {
+	checked := map[string]bool{}
+
+	for _, f := range c.secretFields() {
+		if *f.value == "" || isIndirect(*f.value) {
+			continue
+		}
+
+		origin, ok := c.origins[f.path]
+		if !ok || origin.Line == 0 || checked[origin.Source] {
+			// Not from a file
+			continue
+		}
+		checked[origin.Source] = true
+
+		warning, err := checkPermissions(origin.Source)
+		if err != nil {
+			return err
+		}
+		c.warn(warning)
+	}
+
+	return nil
+}
+
+// resolve expands environment, file and command references in the
+// fields that may hold secrets.
+func (c *Config) resolve() error {
+	for _, f := range c.secretFields() {
+		resolved, warning, err := resolveValue(*f.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+		*f.value = resolved
+		c.warn(warning)
+	}
+
+	return nil
+}
+
+func (c *Config) warn(warning string) {
+	if warning != "" {
+		c.Warnings = append(c.Warnings, warning)
+	}
+}
+
+// Show renders the merged configuration as YAML before secret references
+// are resolved. Literal secrets are masked. With origins set, every value
+// is annotated with the layer it came from.
+func (c *Config) Show(origins bool) ([]byte, error) {
+	if c.raw == nil {
+		return []byte("{}\n"), nil
 	}
 
-	err = yaml.Unmarshal(confFile, c)
-	if err != nil {
+	secrets := map[string]bool{}
+	for _, f := range c.secretFields() {
+		secrets[f.path] = true
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.annotate(c.raw, "", secrets, origins)); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
 		return nil, err
 	}
 
-	return c, nil
+	return buf.Bytes(), nil
+}
+
+// annotate returns a copy of n ready for display.
+func (c *Config) annotate(n *yaml.Node, path string, secrets map[string]bool, origins bool) *yaml.Node {
+	out := *n
+	out.HeadComment, out.LineComment, out.FootComment = "", "", ""
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		out.Content = nil
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := *n.Content[i]
+			key.HeadComment, key.LineComment, key.FootComment = "", "", ""
+
+			childPath := key.Value
+			if path != "" {
+				childPath = path + "." + key.Value
+			}
+			out.Content = append(out.Content, &key, c.annotate(n.Content[i+1], childPath, secrets, origins))
+		}
+		return &out
+	case yaml.SequenceNode:
+		out.Content = nil
+		for _, item := range n.Content {
+			out.Content = append(out.Content, c.annotate(item, path, secrets, false))
+		}
+	case yaml.ScalarNode:
+		if secrets[path] && n.Value != "" && !isIndirect(n.Value) {
+			out.Value = maskSecret(n.Value)
+			out.Style = yaml.DoubleQuotedStyle
+		}
+	}
+
+	if o, ok := c.origins[path]; ok && origins {
+		out.LineComment = o.String()
+	}
+
+	return &out
+}
+
+// maskSecret keeps just enough of a secret to tell two values apart.
+func maskSecret(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", 8) + s[len(s)-4:]
 }
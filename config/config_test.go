@@ -0,0 +1,433 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/severity"
+)
+
+func writeFile(t *testing.T, dir, name, content string, perm os.FileMode) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewConfigResolvesWebhook(t *testing.T) {
+	dir := t.TempDir()
+	secret := writeFile(t, dir, "webhook", "https://hooks.slack.com/services/FILE\n", 0o600)
+	t.Setenv("TEST_SLACK_TOKEN", "ENV")
+
+	cases := []struct {
+		desc  string
+		value string
+		want  string
+	}{
+		{"literal", "https://hooks.slack.com/services/LITERAL", "https://hooks.slack.com/services/LITERAL"},
+		{"environment variable", "https://hooks.slack.com/services/${TEST_SLACK_TOKEN}", "https://hooks.slack.com/services/ENV"},
+		{"secret file", "file:" + secret, "https://hooks.slack.com/services/FILE"},
+		{"command output", "exec:echo https://hooks.slack.com/services/EXEC", "https://hooks.slack.com/services/EXEC"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			cf := writeFile(t, dir, "config.yml", "webhook: \""+c.value+"\"\n", 0o600)
+
+			conf, err := NewConfig(cf)
+			if err != nil {
+				t.Fatalf("NewConfig() error = %v", err)
+			}
+			if conf.WebHook != c.want {
+				t.Errorf("WebHook = %q, want %q", conf.WebHook, c.want)
+			}
+			if len(conf.Warnings) != 0 {
+				t.Errorf("unexpected warnings: %v", conf.Warnings)
+			}
+		})
+	}
+}
+
+func TestNewConfigErrorNamesField(t *testing.T) {
+	dir := t.TempDir()
+	cf := writeFile(t, dir, "config.yml", "webhook: \"${TEST_SLACKBOT_UNSET}\"\n", 0o600)
+
+	_, err := NewConfig(cf)
+	if err == nil {
+		t.Fatal("expected an error for an unset environment variable")
+	}
+	if !strings.HasPrefix(err.Error(), "webhook: ") || !strings.Contains(err.Error(), "TEST_SLACKBOT_UNSET") {
+		t.Errorf("error %q should name the field and the variable", err)
+	}
+}
+
+func TestNewConfigWarnsAboutOpenPermissions(t *testing.T) {
+	dir := t.TempDir()
+	secret := writeFile(t, dir, "webhook", "https://hooks.slack.com/services/FILE", 0o644)
+
+	cf := writeFile(t, dir, "literal.yml", "webhook: \"https://hooks.slack.com/services/LITERAL\"\n", 0o644)
+	conf, err := NewConfig(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Warnings) != 1 || !strings.Contains(conf.Warnings[0], cf) {
+		t.Errorf("literal webhook: warnings = %v, want one about %s", conf.Warnings, cf)
+	}
+
+	cf = writeFile(t, dir, "indirect.yml", "webhook: \"file:"+secret+"\"\n", 0o644)
+	conf, err = NewConfig(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Warnings) != 1 || !strings.Contains(conf.Warnings[0], secret) {
+		t.Errorf("secret file: warnings = %v, want one about %s", conf.Warnings, secret)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	system := writeFile(t, dir, "system.yml", "webhook: \"${SYSTEM_HOOK}\"\n", 0o600)
+	user := writeFile(t, dir, "user.yml", "\nwebhook: \"${USER_HOOK}\"\n", 0o600)
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "conf.d"), "10-team.yml", "\n\nwebhook: \"${TEAM_HOOK}\"\n", 0o600)
+
+	t.Setenv("SYSTEM_HOOK", "system")
+	t.Setenv("USER_HOOK", "user")
+	t.Setenv("TEAM_HOOK", "team")
+
+	cases := []struct {
+		desc   string
+		opts   Options
+		want   string
+		origin string
+	}{
+		{
+			desc:   "system only",
+			opts:   Options{SystemFile: system},
+			want:   "system",
+			origin: system + ":1",
+		},
+		{
+			desc:   "user overrides system",
+			opts:   Options{SystemFile: system, UserFile: user},
+			want:   "user",
+			origin: user + ":2",
+		},
+		{
+			desc:   "conf.d overrides user",
+			opts:   Options{SystemFile: system, UserFile: user, IncludeDir: filepath.Join(dir, "conf.d")},
+			want:   "team",
+			origin: filepath.Join(dir, "conf.d", "10-team.yml") + ":3",
+		},
+		{
+			desc: "environment overrides files",
+			opts: Options{
+				SystemFile: system,
+				Environ:    []string{"SLACKBOT_WEBHOOK=env", "SLACKBOT_UNRELATED=1"},
+			},
+			want:   "env",
+			origin: "env SLACKBOT_WEBHOOK",
+		},
+		{
+			desc: "flags override everything",
+			opts: Options{
+				SystemFile: system,
+				Environ:    []string{"SLACKBOT_WEBHOOK=env"},
+				Flags:      []Override{{Flag: "webhook", Key: "webhook", Value: "flag"}},
+			},
+			want:   "flag",
+			origin: "flag -webhook",
+		},
+		{
+			desc: "missing optional files are skipped",
+			opts: Options{SystemFile: filepath.Join(dir, "missing.yml"), UserFile: user},
+			want: "user", origin: user + ":2",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			conf, err := Load(c.opts)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if conf.WebHook != c.want {
+				t.Errorf("WebHook = %q, want %q", conf.WebHook, c.want)
+			}
+			if o, _ := conf.Origin("webhook"); o.String() != c.origin {
+				t.Errorf("Origin(webhook) = %q, want %q", o, c.origin)
+			}
+		})
+	}
+}
+
+func TestLoadRequiresExplicitFile(t *testing.T) {
+	_, err := Load(Options{File: filepath.Join(t.TempDir(), "missing.yml")})
+	if err == nil {
+		t.Fatal("expected an error for a missing -config file")
+	}
+}
+
+func TestLoadEnvironLists(t *testing.T) {
+	conf, err := Load(Options{Environ: []string{"SLACKBOT_INTERFACES__INCLUDE=eth0, wlan*"}})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := conf.Interfaces.Include; len(got) != 2 || got[0] != "eth0" || got[1] != "wlan*" {
+		t.Errorf("Interfaces.Include = %q, want [eth0 wlan*]", got)
+	}
+
+	for _, kv := range []string{"SLACKBOT_RULES=drop", "SLACKBOT_RULES__NAME=x"} {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, err := Load(Options{Environ: []string{kv}}); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Load(%s) error = %v, want one naming %s", kv, err, name)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	cf := writeFile(t, t.TempDir(), "config.yml", "webhook: \"https://hooks.slack.com/x\"\nwebhok: typo\n", 0o600)
+
+	_, err := NewConfig(cf)
+	if err == nil || !strings.Contains(err.Error(), "line 2: field webhok not found") {
+		t.Errorf("NewConfig() error = %v, want an unknown field error on line 2", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cf := writeFile(t, t.TempDir(), "config.yml", `webhook: "ftp://hooks.slack.com/x"
+destinations:
+  ops:
+    webhook: "https://hooks.slack.com/ops"
+  dev: {}
+default_destinations: [ops, missing]
+`, 0o600)
+
+	conf, err := NewConfig(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, err := range conf.Validate() {
+		got = append(got, err.Error())
+	}
+
+	want := []string{
+		cf + `:1: webhook: must be an http or https URL, got scheme "ftp"`,
+		cf + `:5: destinations.dev.webhook: is required`,
+		cf + `:6: default_destinations: item 2: unknown destination "missing"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDefaultDestinationFromMap(t *testing.T) {
+	cf := writeFile(t, t.TempDir(), "config.yml", `destinations:
+  default:
+    webhook: "https://hooks.slack.com/default"
+`, 0o600)
+
+	conf, err := NewConfig(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := conf.Validate(); len(errs) > 0 {
+		t.Errorf("Validate() = %v, want no errors", errs)
+	}
+	if got := conf.DestinationNames(); len(got) != 1 || got[0] != DefaultDestination {
+		t.Errorf("DestinationNames() = %q, want [default]", got)
+	}
+	targets, err := conf.Targets(conf.DefaultRoute())
+	if err != nil || len(targets) != 1 || targets[0].WebHook != "https://hooks.slack.com/default" {
+		t.Errorf("Targets(DefaultRoute()) = %+v, %v", targets, err)
+	}
+}
+
+func TestInputConfig(t *testing.T) {
+	cf := writeFile(t, t.TempDir(), "config.yml", `webhook: "https://hooks.slack.com/x"
+destinations:
+  ops:
+    webhook: "https://hooks.slack.com/ops"
+    token: "xoxb-123"
+input:
+  max_size: 512KiB
+  binary: upload
+`, 0o600)
+
+	conf, err := NewConfig(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Input.MaxSize != 512<<10 {
+		t.Errorf("MaxSize = %d, want 512 KiB", conf.Input.MaxSize)
+	}
+
+	var got []string
+	for _, err := range conf.Validate() {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		cf + `:4: destinations.ops: token and channel must be set together`,
+		cf + `:8: input.binary: want reject, hexdump or attach, got "upload"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := NewConfig(writeFile(t, t.TempDir(), "config.yml", "input: {max_size: lots}\n", 0o600)); err == nil {
+		t.Error("an invalid size should fail to load")
+	}
+}
+
+func TestInterfacesFilterDefaults(t *testing.T) {
+	cf := writeFile(t, t.TempDir(), "config.yml", "interfaces:\n  include: [\"eth*\"]\n  exclude_cidrs: []\n", 0o600)
+
+	conf, err := NewConfig(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := conf.Interfaces.Filter()
+	if len(f.Include) != 1 || f.Include[0] != "eth*" {
+		t.Errorf("Include = %v, want [eth*]", f.Include)
+	}
+	if len(f.Exclude) == 0 {
+		t.Error("Exclude should keep its default when not set")
+	}
+	if len(f.ExcludeCIDRs) != 0 {
+		t.Errorf("ExcludeCIDRs = %v, want the default turned off", f.ExcludeCIDRs)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	cf := writeFile(t, t.TempDir(), "config.yml", `webhook: "https://hooks.slack.com/x"
+timezone: Mars/Olympus
+rules:
+  - match: {text: "error"}
+    actions: {route: [default, dba]}
+`, 0o600)
+
+	conf, err := NewConfig(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, err := range conf.Validate() {
+		got = append(got, err.Error())
+	}
+
+	want := []string{
+		cf + `:2: timezone: unknown time zone Mars/Olympus`,
+		cf + `:4: rules: item 1: unknown destination "dba"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMentions(t *testing.T) {
+	cf := writeFile(t, t.TempDir(), "config.yml", `webhook: "https://hooks.slack.com/x"
+oncall:
+  users: {alice: U0111AAAA, bob: U0222BBBB, dba: S0333CCCC}
+  rotation: [alice, bob]
+  start: "2024-03-04 09:00"
+  timezone: UTC
+  overrides:
+    - {user: dba, from: "2024-03-20 00:00", to: "2024-03-21 00:00"}
+`, 0o600)
+
+	conf, err := NewConfig(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := conf.Validate(); len(errs) > 0 {
+		t.Fatalf("Validate() = %v", errs)
+	}
+
+	week2 := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		desc  string
+		names []string
+		level severity.Level
+		when  time.Time
+		want  string
+	}{
+		{"info mentions nobody", nil, severity.Info, week2, ""},
+		{"error mentions the on-caller", nil, severity.Error, week2, "<@U0222BBBB>"},
+		{"override", nil, severity.Critical, time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC), "<!subteam^S0333CCCC>"},
+		{"rule mentions are deduplicated", []string{"bob", "oncall", "<!here>"}, severity.Error, week2, "<@U0222BBBB> <!here>"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := conf.Mentions(c.names, c.level, c.when)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, " ") != c.want {
+				t.Errorf("Mentions() = %q, want %q", got, c.want)
+			}
+		})
+	}
+
+	if got, err := conf.Mentions([]string{"carol", "alice"}, severity.Info, week2); err == nil {
+		t.Error("expected an error for an unknown user")
+	} else if strings.Join(got, " ") != "<@U0111AAAA>" {
+		t.Errorf("Mentions() = %q, want the known users still mentioned", got)
+	}
+	if got, err := conf.Mentions([]string{"SRE"}, severity.Info, week2); err == nil || len(got) > 0 {
+		t.Errorf("Mentions(SRE) = %q, %v; want a name that isn't a Slack ID rejected", got, err)
+	}
+}
+
+func TestMentionsFallback(t *testing.T) {
+	cf := writeFile(t, t.TempDir(), "config.yml", `webhook: "https://hooks.slack.com/x"
+oncall:
+  users: {alice: U0111AAAA, ops: S0333CCCC}
+  rotation: [alice, ghost]
+  start: "2024-03-04 09:00"
+  timezone: UTC
+  fallback: ops
+  overrides:
+    - {user: alice, from: "2024-03-21 00:00", to: "2024-03-20 00:00"}
+`, 0o600)
+
+	conf, err := NewConfig(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, err := range conf.Validate() {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		cf + `:4: oncall.rotation: item 2: unknown user "ghost"`,
+		cf + `:9: oncall.overrides: item 1: to must be after from`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// ghost is on call in the second week
+	week2 := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+	if m, err := conf.Mentions(nil, severity.Critical, week2); err == nil || strings.Join(m, " ") != "<!subteam^S0333CCCC>" {
+		t.Errorf("Mentions() = %q, %v; want the fallback and an error", m, err)
+	}
+
+	conf.OnCall.Start = "next monday"
+	if m, err := conf.Mentions([]string{"alice"}, severity.Critical, week2); err == nil || strings.Join(m, " ") != "<@U0111AAAA> <!subteam^S0333CCCC>" {
+		t.Errorf("Mentions() with a bad start = %q, %v; want alice, the fallback and an error", m, err)
+	}
+}
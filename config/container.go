@@ -0,0 +1,8 @@
+package config
+
+// ContainerConfig controls container and Kubernetes detection, which is
+// on by default and only adds context when slackbot runs in a container.
+type ContainerConfig struct {
+	Disabled   bool   `yaml:"disabled"`
+	LabelsFile string `yaml:"labels_file"` // downward API labels volume, default /etc/podinfo/labels
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultDestination names the destination defined by the top-level webhook.
+const DefaultDestination = "default"
+
+// Destination is a place messages can be sent to.
+type Destination struct {
+	WebHook string `yaml:"webhook"`
+
+	// Token is a bot token for the Web API. With a token, messages are
+	// posted with the Web API instead of the webhook, which is needed to
+	// update them and to upload files. Channel is the ID of the channel it
+	// posts to, e.g. C0123ABCD.
+	Token   string `yaml:"token"`
+	Channel string `yaml:"channel"`
+}
+
+// WebAPI reports whether messages go through the Web API.
+func (d Destination) WebAPI() bool {
+	return d.Token != ""
+}
+
+// Target is a resolved destination ready to send to.
+type Target struct {
+	Name string
+	Destination
+}
+
+// Destination returns the destination with the given name.
+func (c *Config) Destination(name string) (Destination, bool) {
+	if name == DefaultDestination && c.WebHook != "" {
+		return Destination{WebHook: c.WebHook}, true
+	}
+
+	d, ok := c.Destinations[name]
+	if !ok || d == nil {
+		return Destination{}, false
+	}
+	return *d, true
+}
+
+// DestinationNames lists every configured destination, sorted by name.
+func (c *Config) DestinationNames() []string {
+	var names []string
+	if c.WebHook != "" {
+		names = append(names, DefaultDestination)
+	}
+	for _, name := range sortedKeys(c.Destinations) {
+		// Without a top-level webhook, destinations.default is the default
+		if name != DefaultDestination || c.WebHook == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultRoute returns the destinations for messages that no rule routes.
+func (c *Config) DefaultRoute() []string {
+	if len(c.DefaultDestinations) > 0 {
+		return c.DefaultDestinations
+	}
+	return []string{DefaultDestination}
+}
+
+// Targets resolves destination names, failing on the first unknown one.
+func (c *Config) Targets(names []string) ([]Target, error) {
+	targets := make([]Target, 0, len(names))
+	for _, name := range names {
+		d, ok := c.Destination(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown destination %q", name)
+		}
+		targets = append(targets, Target{Name: name, Destination: d})
+	}
+	return targets, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
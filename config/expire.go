@@ -0,0 +1,43 @@
+package config
+
+import "fmt"
+
+// What happens to a message sent with -ttl once it expires
+const (
+	ExpireDelete   = "delete"
+	ExpireCollapse = "collapse"
+)
+
+// DefaultExpireNote starts the line a collapsed message is replaced with.
+const DefaultExpireNote = ":white_check_mark: Resolved"
+
+// ExpireConfig configures how messages sent with -ttl are removed by
+// `slackbot gc` or `slackbot serve`.
+type ExpireConfig struct {
+	Action string `yaml:"action"` // delete, the default, or collapse to a one-line note
+	Note   string `yaml:"note"`   // default ":white_check_mark: Resolved"
+}
+
+// ActionName returns Action with its default.
+func (e ExpireConfig) ActionName() string {
+	if e.Action == "" {
+		return ExpireDelete
+	}
+	return e.Action
+}
+
+// NoteText returns Note with its default.
+func (e ExpireConfig) NoteText() string {
+	if e.Note == "" {
+		return DefaultExpireNote
+	}
+	return e.Note
+}
+
+func (e ExpireConfig) validate(fail func(path string, err error)) {
+	switch e.Action {
+	case "", ExpireDelete, ExpireCollapse:
+	default:
+		fail("expire.action", fmt.Errorf("want %s or %s, got %q", ExpireDelete, ExpireCollapse, e.Action))
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/httpclient"
+)
+
+// HTTPConfig configures every outbound HTTP request: to Slack, to public
+// IP providers and, except for the proxy, to cloud metadata services.
+type HTTPConfig struct {
+	Proxy         string        `yaml:"proxy"`           // http, https, socks5 or socks5h URL, default $HTTPS_PROXY
+	NoProxy       []string      `yaml:"no_proxy"`        // hosts, .domains, IPs and CIDRs reached directly
+	CAFile        string        `yaml:"ca_file"`         // PEM bundle trusted in addition to the system roots
+	CertFile      string        `yaml:"cert_file"`       // client certificate for mutual TLS
+	KeyFile       string        `yaml:"key_file"`        // its private key
+	Timeout       time.Duration `yaml:"timeout"`         // whole request, default 10s
+	DialTimeout   time.Duration `yaml:"dial_timeout"`    // TCP connect, default 5s
+	TLSMinVersion string        `yaml:"tls_min_version"` // 1.2 or 1.3
+	SourceAddress string        `yaml:"source_address"`  // local IP address of outgoing connections
+}
+
+// Options returns the settings for package httpclient.
+func (h HTTPConfig) Options() httpclient.Options {
+	return httpclient.Options{
+		Proxy:         h.Proxy,
+		NoProxy:       h.NoProxy,
+		CAFile:        h.CAFile,
+		CertFile:      h.CertFile,
+		KeyFile:       h.KeyFile,
+		Timeout:       h.Timeout,
+		DialTimeout:   h.DialTimeout,
+		TLSMinVersion: h.TLSMinVersion,
+		SourceAddress: h.SourceAddress,
+	}
+}
+
+// proxyHasSecret reports whether the proxy URL holds credentials or refers
+// to a secret, so that it's resolved and masked like a webhook.
+func (h HTTPConfig) proxyHasSecret() bool {
+	return strings.Contains(h.Proxy, "@") || isIndirect(h.Proxy)
+}
+
+// checkKeyFile warns about a client key readable by other users.
+func (c *Config) checkKeyFile() {
+	if c.HTTP.KeyFile == "" {
+		return
+	}
+	if _, err := os.Stat(c.HTTP.KeyFile); err != nil {
+		// Reported when the client is built
+		return
+	}
+	warning, _ := checkPermissions(c.HTTP.KeyFile)
+	c.warn(warning)
+}
+
+func (h HTTPConfig) validate(fail func(path string, err error)) {
+	opts := h.Options()
+
+	err := opts.Validate()
+	if err == nil {
+		// Load the CA bundle and client certificate too
+		_, err = opts.Transport()
+	}
+	if err == nil {
+		return
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			fail("http", e)
+		}
+		return
+	}
+	fail("http", err)
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/maxkulish/slackbot/input"
+	"github.com/maxkulish/slackbot/termtext"
+)
+
+// What to do with binary input
+const (
+	BinaryReject  = "reject"
+	BinaryHexDump = "hexdump"
+	BinaryAttach  = "attach"
+)
+
+// InputConfig controls how text read from stdin is cleaned up. By default
+// it is normalized as a terminal would show it: progress updates collapse
+// to their final state and escape sequences are removed.
+type InputConfig struct {
+	// Raw keeps escape sequences and control characters as they are.
+	Raw bool `yaml:"raw"`
+
+	// DiffMarkers turns red lines into "- " and green lines into "+ ".
+	DiffMarkers bool `yaml:"diff_markers"`
+
+	// MaxSize is the most input read, default 8MiB. Longer text keeps its end.
+	MaxSize ByteSize `yaml:"max_size"`
+
+	// Binary is reject (the default), hexdump to send the first bytes, or
+	// attach to upload the input as a file, which takes a destination token.
+	Binary string `yaml:"binary"`
+}
+
+// TermText returns the options for termtext.Normalize.
+func (i InputConfig) TermText() termtext.Options {
+	return termtext.Options{DiffMarkers: i.DiffMarkers}
+}
+
+// BinaryPolicy returns Binary with its default.
+func (i InputConfig) BinaryPolicy() string {
+	if i.Binary == "" {
+		return BinaryReject
+	}
+	return i.Binary
+}
+
+func (i InputConfig) validate(fail func(path string, err error)) {
+	switch i.Binary {
+	case "", BinaryReject, BinaryHexDump, BinaryAttach:
+	default:
+		fail("input.binary", fmt.Errorf("want %s, %s or %s, got %q", BinaryReject, BinaryHexDump, BinaryAttach, i.Binary))
+	}
+	if i.MaxSize < 0 {
+		fail("input.max_size", fmt.Errorf("must not be negative, got %d", i.MaxSize))
+	}
+}
+
+// ByteSize is a size in bytes, written as a number of bytes or with a unit
+// such as 512KiB, 8MiB or 10MB.
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// UnmarshalText parses a size from YAML.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid size %q, want e.g. 8MiB", text)
+	}
+	*b = ByteSize(n * float64(unit))
+	return nil
+}
+
+func (b ByteSize) String() string {
+	return input.FormatSize(int64(b))
+}
@@ -0,0 +1,31 @@
+package config
+
+import "github.com/maxkulish/slackbot/localip"
+
+// InterfacesConfig selects the local addresses shown in messages.
+// A list that isn't set keeps its default from localip.DefaultFilter;
+// an empty list ([]) turns the default off.
+type InterfacesConfig struct {
+	Include      []string `yaml:"include"`       // interface globs, e.g. eth*
+	Exclude      []string `yaml:"exclude"`       // interface globs, e.g. docker*
+	IncludeCIDRs []string `yaml:"include_cidrs"` // e.g. 10.0.0.0/8
+	ExcludeCIDRs []string `yaml:"exclude_cidrs"` // e.g. fe80::/10
+}
+
+// Filter returns the configured filter on top of the defaults.
+func (i InterfacesConfig) Filter() localip.Filter {
+	f := localip.DefaultFilter()
+	if i.Include != nil {
+		f.Include = i.Include
+	}
+	if i.Exclude != nil {
+		f.Exclude = i.Exclude
+	}
+	if i.IncludeCIDRs != nil {
+		f.IncludeCIDRs = i.IncludeCIDRs
+	}
+	if i.ExcludeCIDRs != nil {
+		f.ExcludeCIDRs = i.ExcludeCIDRs
+	}
+	return f
+}
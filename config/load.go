@@ -0,0 +1,408 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix is the prefix of environment variables that override config values.
+	// Nested keys are separated by a double underscore: SLACKBOT_PUBLIC_IP__TIMEOUT.
+	EnvPrefix = "SLACKBOT_"
+
+	// EnvConfigFile names an explicit config file, like the -config flag.
+	EnvConfigFile = "SLACKBOT_CONFIG"
+
+	DefaultSystemFile = "/etc/slackbot/config.yml"
+	DefaultIncludeDir = "/etc/slackbot/conf.d"
+)
+
+// Options lists the configuration layers in the order they are applied.
+// Later layers override earlier ones: mappings are merged key by key,
+// while scalars and lists are replaced as a whole.
+type Options struct {
+	SystemFile string     // optional, /etc/slackbot/config.yml
+	UserFile   string     // optional, ~/.config/slackbot/config.yml
+	IncludeDir string     // optional, every *.yml file in lexical order
+	File       string     // required if set, from -config or SLACKBOT_CONFIG
+	Environ    []string   // SLACKBOT_* variables in KEY=value form
+	Flags      []Override // values set on the command line
+
+	// SkipResolve leaves ${VAR}, file: and exec: references unexpanded,
+	// so that a config can be inspected without access to its secrets.
+	SkipResolve bool
+}
+
+// Override is a config value set by a command-line flag.
+type Override struct {
+	Flag  string // flag name without the dash, e.g. "facts"
+	Key   string // dotted key path, e.g. "host_facts"
+	Value string
+}
+
+// Origin describes where a config value came from.
+type Origin struct {
+	Source string // file path, "env SLACKBOT_WEBHOOK" or "flag -webhook"
+	Line   int    // line in Source, zero when not applicable
+}
+
+func (o Origin) String() string {
+	if o.Line > 0 {
+		return fmt.Sprintf("%s:%d", o.Source, o.Line)
+	}
+	return o.Source
+}
+
+// DefaultOptions returns the standard layers for the current user and environment.
+func DefaultOptions() Options {
+	return Options{
+		SystemFile: DefaultSystemFile,
+		UserFile:   userConfigFile(),
+		IncludeDir: DefaultIncludeDir,
+		Environ:    os.Environ(),
+	}
+}
+
+func userConfigFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "slackbot", "config.yml")
+}
+
+// Load reads and merges every configuration layer described by opts.
+// Missing optional files are skipped.
+func Load(opts Options) (*Config, error) {
+	l := &loader{origins: map[*yaml.Node]Origin{}}
+
+	if err := l.addFile(opts.SystemFile, false); err != nil {
+		return nil, err
+	}
+	if err := l.addFile(opts.UserFile, false); err != nil {
+		return nil, err
+	}
+	if err := l.addIncludeDir(opts.IncludeDir); err != nil {
+		return nil, err
+	}
+	if err := l.addFile(opts.File, true); err != nil {
+		return nil, err
+	}
+	if err := l.addEnviron(opts.Environ); err != nil {
+		return nil, err
+	}
+	if err := l.addFlags(opts.Flags); err != nil {
+		return nil, err
+	}
+
+	return l.config(opts.SkipResolve)
+}
+
+type loader struct {
+	root    *yaml.Node
+	origins map[*yaml.Node]Origin
+	sources []string
+}
+
+func (l *loader) addFile(path string, required bool) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := checkKnownFields(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		// Empty file
+		l.sources = append(l.sources, path)
+		return nil
+	}
+
+	node := doc.Content[0]
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: line %d: top level must be a mapping", path, node.Line)
+	}
+
+	l.mark(node, func(n *yaml.Node) Origin { return Origin{Source: path, Line: n.Line} })
+	l.merge(node)
+	l.sources = append(l.sources, path)
+
+	return nil
+}
+
+// checkKnownFields decodes a single layer strictly, so that a misspelled key
+// is reported with its line number instead of being silently ignored.
+func checkKnownFields(data []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&Config{}); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *loader) addIncludeDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		if err := l.addFile(f, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *loader) addEnviron(environ []string) error {
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) || name == EnvConfigFile {
+			continue
+		}
+
+		path := strings.Split(strings.ToLower(strings.TrimPrefix(name, EnvPrefix)), "__")
+		node, err := valueNode(path, value)
+		if errors.Is(err, errUnknownKey) {
+			// Not every SLACKBOT_ variable is meant for us
+			continue
+		} else if err != nil {
+			return fmt.Errorf("env %s: %w", name, err)
+		}
+
+		l.set(path, node, Origin{Source: "env " + name})
+	}
+
+	return nil
+}
+
+func (l *loader) addFlags(flags []Override) error {
+	for _, f := range flags {
+		path := strings.Split(f.Key, ".")
+		node, err := valueNode(path, f.Value)
+		if errors.Is(err, errUnknownKey) {
+			return fmt.Errorf("flag -%s sets unknown config key %q", f.Flag, f.Key)
+		} else if err != nil {
+			return fmt.Errorf("flag -%s: %w", f.Flag, err)
+		}
+		l.set(path, node, Origin{Source: "flag -" + f.Flag})
+	}
+
+	return nil
+}
+
+// set overrides a single value, building the nested mappings around it.
+func (l *loader) set(path []string, node *yaml.Node, origin Origin) {
+	for i := len(path) - 1; i >= 0; i-- {
+		node = &yaml.Node{
+			Kind:    yaml.MappingNode,
+			Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: path[i]}, node},
+		}
+	}
+
+	l.mark(node, func(*yaml.Node) Origin { return origin })
+	l.merge(node)
+	l.sources = append(l.sources, origin.Source)
+}
+
+// valueNode returns the node that sets the config value at a key path from
+// a string. A list of scalars is given comma-separated, e.g. eth0,eth1;
+// other lists can only be set in a file.
+func valueNode(path []string, value string) (*yaml.Node, error) {
+	t, err := keyType(reflect.TypeOf(Config{}), path)
+	if err != nil {
+		return nil, err
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Slice {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: value}, nil
+	}
+
+	elem := t.Elem()
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	switch elem.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice:
+		return nil, fmt.Errorf("%s is a list of mappings; set it in a config file", strings.Join(path, "."))
+	}
+
+	node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: v})
+		}
+	}
+	return node, nil
+}
+
+// mark records the origin of every node in a layer before it is merged.
+func (l *loader) mark(n *yaml.Node, origin func(*yaml.Node) Origin) {
+	l.origins[n] = origin(n)
+	for _, child := range n.Content {
+		l.mark(child, origin)
+	}
+}
+
+func (l *loader) merge(layer *yaml.Node) {
+	if l.root == nil {
+		l.root = layer
+		return
+	}
+	mergeNodes(l.root, layer)
+}
+
+// mergeNodes merges mapping src into mapping dst. Keys present in both are
+// merged recursively when both values are mappings and replaced otherwise.
+func mergeNodes(dst, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+
+		j := mappingIndex(dst, key.Value)
+		switch {
+		case j < 0:
+			dst.Content = append(dst.Content, key, value)
+		case dst.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeNodes(dst.Content[j+1], value)
+		default:
+			dst.Content[j+1] = value
+		}
+	}
+}
+
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *loader) config(skipResolve bool) (*Config, error) {
+	c := &Config{
+		Sources: l.sources,
+		origins: map[string]Origin{},
+		raw:     l.root,
+	}
+
+	if l.root == nil {
+		return c, nil
+	}
+
+	if err := l.root.Decode(c); err != nil {
+		return nil, err
+	}
+	l.collectOrigins(l.root, "", c.origins)
+
+	if err := c.checkLiteralSecrets(); err != nil {
+		return nil, err
+	}
+	c.checkKeyFile()
+	if skipResolve {
+		return c, nil
+	}
+	if err := c.resolve(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// collectOrigins maps each dotted key path to the origin of its value.
+// A merged mapping keeps the origin of the layer that introduced it.
+func (l *loader) collectOrigins(n *yaml.Node, prefix string, out map[string]Origin) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		path := n.Content[i].Value
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		value := n.Content[i+1]
+		out[path] = l.origins[value]
+		if value.Kind == yaml.MappingNode {
+			l.collectOrigins(value, path, out)
+		}
+	}
+}
+
+var errUnknownKey = errors.New("unknown config key")
+
+// keyType returns the type of the value at a key path in the config struct
+// t. A path can't lead into the items of a list.
+func keyType(t reflect.Type, path []string) (reflect.Type, error) {
+	if len(path) == 0 {
+		return nil, errUnknownKey
+	}
+
+	for i, key := range path {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			f, ok := fieldByYAMLName(t, key)
+			if !ok {
+				return nil, errUnknownKey
+			}
+			t = f.Type
+		case reflect.Slice:
+			return nil, fmt.Errorf("%s is a list; set it in a config file", strings.Join(path[:i], "."))
+		default:
+			return nil, errUnknownKey
+		}
+	}
+
+	return t, nil
+}
+
+func fieldByYAMLName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if tag == name && tag != "-" {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/maxkulish/slackbot/maintenance"
+	"github.com/maxkulish/slackbot/severity"
+)
+
+// MaintenanceConfig configures quiet hours and what happens to messages
+// during them and during windows from `slackbot maintenance start`.
+type MaintenanceConfig struct {
+	Policy         string          `yaml:"policy"`          // hold (default) or drop
+	BypassSeverity *severity.Level `yaml:"bypass_severity"` // sent anyway from this level, default none
+	QuietHours     []QuietHours    `yaml:"quiet_hours"`
+}
+
+// QuietHours is a recurring daily quiet period in the config timezone.
+type QuietHours struct {
+	Time string   `yaml:"time"` // e.g. 22:00-07:00
+	Days []string `yaml:"days"` // days the period starts on, default every day
+	Host string   `yaml:"host"` // hostname glob, default every host
+}
+
+// Quiet parses the quiet hours.
+func (m MaintenanceConfig) Quiet() ([]maintenance.Quiet, error) {
+	var quiet []maintenance.Quiet
+	for i, qh := range m.QuietHours {
+		q, err := maintenance.NewQuiet(qh.Time, qh.Days, qh.Host)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i+1, err)
+		}
+		quiet = append(quiet, q)
+	}
+	return quiet, nil
+}
+
+func (m MaintenanceConfig) validate(fail func(path string, err error)) {
+	switch m.Policy {
+	case "", maintenance.PolicyHold, maintenance.PolicyDrop:
+	default:
+		fail("maintenance.policy", fmt.Errorf("want %s or %s, got %q", maintenance.PolicyHold, maintenance.PolicyDrop, m.Policy))
+	}
+
+	if _, err := m.Quiet(); err != nil {
+		fail("maintenance.quiet_hours", err)
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/maxkulish/slackbot/truncate"
+)
+
+// maxBlockChars is the most a message can take in its section block,
+// whose text Slack caps at 3000 characters, code fences included.
+const maxBlockChars = 3000 - 6
+
+// MessageConfig limits the size of the message text. Longer messages keep
+// their first and last lines with a notice in between.
+type MessageConfig struct {
+	MaxChars  int `yaml:"max_chars"`  // default 2900
+	MaxLines  int `yaml:"max_lines"`  // default no limit
+	HeadLines int `yaml:"head_lines"` // lines kept from the start, default 10
+	TailLines int `yaml:"tail_lines"` // lines kept from the end, at least, default 30
+}
+
+// Limits returns the limits with defaults for unset values.
+func (m MessageConfig) Limits() truncate.Limits {
+	l := truncate.Default
+	if m.MaxChars > 0 {
+		l.MaxChars = m.MaxChars
+	}
+	if m.MaxLines > 0 {
+		l.MaxLines = m.MaxLines
+	}
+	if m.HeadLines > 0 {
+		l.Head = m.HeadLines
+	}
+	if m.TailLines > 0 {
+		l.Tail = m.TailLines
+	}
+	return l
+}
+
+func (m MessageConfig) validate(fail func(path string, err error)) {
+	if m.MaxChars > maxBlockChars {
+		fail("message.max_chars", fmt.Errorf("must be at most %d, Slack's limit for a block", maxBlockChars))
+	}
+	for _, f := range []struct {
+		path  string
+		value int
+	}{
+		{"message.max_chars", m.MaxChars},
+		{"message.max_lines", m.MaxLines},
+		{"message.head_lines", m.HeadLines},
+		{"message.tail_lines", m.TailLines},
+	} {
+		if f.value < 0 {
+			fail(f.path, fmt.Errorf("must not be negative, got %d", f.value))
+		}
+	}
+}
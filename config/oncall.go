@@ -0,0 +1,205 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/oncall"
+	"github.com/maxkulish/slackbot/severity"
+)
+
+// TimeLayout is the format of dates and times in the config, read in the
+// configured timezone.
+const TimeLayout = "2006-01-02 15:04"
+
+// OnCallMention is the mention name that stands for whoever is on call.
+const OnCallMention = "oncall"
+
+// OnCallConfig names Slack users and groups and defines the on-call rotation.
+type OnCallConfig struct {
+	// Users maps names used in rotations and rule mentions to Slack user
+	// IDs (U…, W…) or user group IDs (S…).
+	Users map[string]string `yaml:"users"`
+
+	Rotation    []string         `yaml:"rotation"`     // names from users, in order
+	Start       string           `yaml:"start"`        // first handoff, e.g. "2024-03-04 09:00"
+	Shift       time.Duration    `yaml:"shift"`        // default 168h, a weekly handoff
+	Timezone    string           `yaml:"timezone"`     // default: the config timezone
+	Overrides   []OnCallOverride `yaml:"overrides"`    // take precedence over the rotation
+	MinSeverity *severity.Level  `yaml:"min_severity"` // mention the on-caller from this level, default error
+
+	// Fallback is mentioned when the on-caller can't be worked out, such
+	// as a user group or <!channel>.
+	Fallback string `yaml:"fallback"`
+}
+
+// OnCallOverride puts a user on call for a period, such as a swap.
+type OnCallOverride struct {
+	User string `yaml:"user"`
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// OnCallSchedule returns the rotation, or false if none is configured.
+// Errors are *ValidationError.
+func (c *Config) OnCallSchedule() (oncall.Schedule, bool, error) {
+	o := c.OnCall
+	if len(o.Rotation) == 0 && len(o.Overrides) == 0 {
+		return oncall.Schedule{}, false, nil
+	}
+
+	loc, err := c.OnCallLocation()
+	if err != nil {
+		return oncall.Schedule{}, false, &ValidationError{Path: "oncall.timezone", Err: err}
+	}
+
+	s := oncall.Schedule{Members: o.Rotation, Shift: o.Shift}
+	if len(o.Rotation) > 0 {
+		if s.Start, err = time.ParseInLocation(TimeLayout, o.Start, loc); err != nil {
+			return oncall.Schedule{}, false, &ValidationError{Path: "oncall.start", Err: fmt.Errorf("want %q, got %q", TimeLayout, o.Start)}
+		}
+	}
+
+	for i, ov := range o.Overrides {
+		from, err := time.ParseInLocation(TimeLayout, ov.From, loc)
+		if err != nil {
+			return oncall.Schedule{}, false, &ValidationError{Path: "oncall.overrides", Err: fmt.Errorf("item %d: from: want %q, got %q", i+1, TimeLayout, ov.From)}
+		}
+		to, err := time.ParseInLocation(TimeLayout, ov.To, loc)
+		if err != nil {
+			return oncall.Schedule{}, false, &ValidationError{Path: "oncall.overrides", Err: fmt.Errorf("item %d: to: want %q, got %q", i+1, TimeLayout, ov.To)}
+		}
+		s.Overrides = append(s.Overrides, oncall.Override{Member: ov.User, From: from, To: to})
+	}
+
+	return s, true, nil
+}
+
+// OnCallLocation returns the timezone of the rotation: oncall.timezone, or
+// the config timezone.
+func (c *Config) OnCallLocation() (*time.Location, error) {
+	if c.OnCall.Timezone != "" {
+		return time.LoadLocation(c.OnCall.Timezone)
+	}
+	return c.Location()
+}
+
+// Mention turns a user or group name into a Slack mention. Slack IDs and
+// ready-made mentions such as <!here> are accepted as they are.
+func (c *Config) Mention(name string) (string, bool) {
+	if id, ok := c.OnCall.Users[name]; ok {
+		return oncall.Mention(id), true
+	}
+	if oncall.IsID(name) || strings.HasPrefix(name, "<") {
+		return oncall.Mention(name), true
+	}
+	return "", false
+}
+
+// Mentions resolves the names requested by rules into Slack mentions and,
+// for messages of at least the on-call severity, adds whoever is on call.
+// "oncall" may also be requested explicitly. Duplicates are removed.
+//
+// Names that can't be resolved are left out and reported in the error,
+// along with the mentions that could be, so that the message can still be
+// sent. The fallback stands in for an on-caller that can't be resolved.
+func (c *Config) Mentions(names []string, level severity.Level, now time.Time) ([]string, error) {
+	minLevel := severity.Error
+	if c.OnCall.MinSeverity != nil {
+		minLevel = *c.OnCall.MinSeverity
+	}
+
+	var errs []error
+	schedule, ok, err := c.OnCallSchedule()
+	if err != nil {
+		errs = append(errs, err)
+		ok = true // the fallback stands in
+	}
+	if ok && level >= minLevel {
+		names = append(names, OnCallMention)
+	}
+
+	var mentions []string
+	seen := map[string]bool{}
+	add := func(name string) bool {
+		m, ok := c.Mention(name)
+		if ok && !seen[m] {
+			seen[m] = true
+			mentions = append(mentions, m)
+		}
+		return ok
+	}
+
+	for _, name := range names {
+		if name != OnCallMention {
+			if !add(name) {
+				errs = append(errs, fmt.Errorf("unknown user %q in mentions", name))
+			}
+			continue
+		}
+
+		if err == nil {
+			shift, found := schedule.At(now)
+			if !found {
+				continue
+			}
+			if add(shift.Member) {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("unknown user %q on call", shift.Member))
+		}
+		if c.OnCall.Fallback != "" && !add(c.OnCall.Fallback) {
+			errs = append(errs, fmt.Errorf("unknown user %q in oncall.fallback", c.OnCall.Fallback))
+		}
+	}
+
+	return mentions, errors.Join(errs...)
+}
+
+func (c *Config) validateOnCall(fail func(path string, err error)) {
+	for _, name := range sortedKeys(c.OnCall.Users) {
+		if id := c.OnCall.Users[name]; !oncall.IsID(id) {
+			fail("oncall.users."+name, fmt.Errorf("want a Slack user (U…) or group (S…) ID, got %q", id))
+		}
+	}
+
+	for i, name := range c.OnCall.Rotation {
+		if _, ok := c.Mention(name); !ok {
+			fail("oncall.rotation", fmt.Errorf("item %d: unknown user %q", i+1, name))
+		}
+	}
+	for i, ov := range c.OnCall.Overrides {
+		if _, ok := c.Mention(ov.User); !ok {
+			fail("oncall.overrides", fmt.Errorf("item %d: unknown user %q", i+1, ov.User))
+		}
+	}
+	if f := c.OnCall.Fallback; f != "" {
+		if _, ok := c.Mention(f); !ok {
+			fail("oncall.fallback", fmt.Errorf("unknown user %q", f))
+		}
+	}
+	if c.OnCall.Shift < 0 {
+		fail("oncall.shift", fmt.Errorf("must not be negative, got %s", c.OnCall.Shift))
+	}
+
+	var verr *ValidationError
+	if s, _, err := c.OnCallSchedule(); errors.As(err, &verr) {
+		fail(verr.Path, verr.Err)
+	} else if err == nil {
+		for i, ov := range s.Overrides {
+			if !ov.To.After(ov.From) {
+				fail("oncall.overrides", fmt.Errorf("item %d: to must be after from", i+1))
+			}
+		}
+	}
+
+	for i, r := range c.Rules {
+		for _, name := range r.Actions.Mention {
+			if _, ok := c.Mention(name); !ok && name != OnCallMention {
+				fail("rules", fmt.Errorf("item %d: unknown user %q in mention", i+1, name))
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maxkulish/slackbot/localip"
+)
+
+// PublicIPConfig controls public address discovery. Unset values fall back
+// to localip.DefaultPublicIPOptions.
+type PublicIPConfig struct {
+	Disabled   bool          `yaml:"disabled"`
+	Providers  []string      `yaml:"providers"`   // ipify, ifconfig.me, icanhazip, aws or a URL
+	Families   []string      `yaml:"families"`    // ipv4, ipv6
+	Timeout    time.Duration `yaml:"timeout"`     // e.g. 3s
+	CacheTTL   time.Duration `yaml:"cache_ttl"`   // e.g. 1h
+	FailureTTL time.Duration `yaml:"failure_ttl"` // e.g. 10m
+}
+
+func (p PublicIPConfig) validate(fail func(path string, err error)) {
+	for i, provider := range p.Providers {
+		if err := localip.ValidateProvider(provider); err != nil {
+			fail("public_ip.providers", fmt.Errorf("item %d: %w", i+1, err))
+		}
+	}
+	for i, family := range p.Families {
+		if err := localip.ValidateFamily(family); err != nil {
+			fail("public_ip.families", fmt.Errorf("item %d: %w", i+1, err))
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	filePrefix = "file:"
+	execPrefix = "exec:"
+
+	// execTimeout bounds how long a secret helper such as `pass` may run.
+	execTimeout = 10 * time.Second
+)
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// isIndirect reports whether a config value refers to a secret stored outside
+// the config file rather than holding it literally.
+func isIndirect(value string) bool {
+	return strings.HasPrefix(value, filePrefix) ||
+		strings.HasPrefix(value, execPrefix) ||
+		envRefPattern.MatchString(value)
+}
+
+// resolveValue expands a config value into its final form:
+//
+//	${SLACK_WEBHOOK}               environment variable, may be embedded in a string
+//	file:/run/secrets/webhook      contents of a file, trailing newline trimmed
+//	exec:pass show slack/webhook   standard output of a command, run without a shell
+//
+// Any other value is returned unchanged. The returned warning is non-empty
+// when a secret file has permissions that are too open.
+func resolveValue(value string) (resolved, warning string, err error) {
+	switch {
+	case strings.HasPrefix(value, filePrefix):
+		return readSecretFile(strings.TrimSpace(strings.TrimPrefix(value, filePrefix)))
+	case strings.HasPrefix(value, execPrefix):
+		resolved, err = runSecretCommand(strings.TrimPrefix(value, execPrefix))
+		return resolved, "", err
+	default:
+		resolved, err = expandEnv(value)
+		return resolved, "", err
+	}
+}
+
+// expandEnv replaces every ${NAME} reference with the value of the
+// environment variable. Unset variables are an error so that a typo
+// doesn't silently produce an empty webhook.
+func expandEnv(value string) (string, error) {
+	var missing []string
+
+	expanded := envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envRefPattern.FindStringSubmatch(ref)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
+
+func readSecretFile(path string) (string, string, error) {
+	if path == "" {
+		return "", "", errors.New("file: reference without a path")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	warning, err := checkPermissions(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), warning, nil
+}
+
+func runSecretCommand(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("exec: reference without a command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("command %q failed: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("command %q failed: %w", args[0], err)
+	}
+
+	// Password managers print the secret on the first line and may
+	// append metadata after it, as `pass` does.
+	out, _, _ := strings.Cut(stdout.String(), "\n")
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return "", fmt.Errorf("command %q printed nothing", args[0])
+	}
+
+	return out, nil
+}
+
+// checkPermissions returns a warning when a file that holds a secret can be
+// read or written by anyone other than its owner.
+func checkPermissions(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return fmt.Sprintf("%s holds a secret but has permissions %04o; restrict it with chmod 600", path, perm), nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maxkulish/slackbot/rules"
+)
+
+// Location returns the configured timezone, or the local one if unset.
+func (c *Config) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// RuleEngine compiles the configured rules and templates.
+func (c *Config) RuleEngine() (*rules.Engine, error) {
+	loc, err := c.Location()
+	if err != nil {
+		return nil, fmt.Errorf("timezone: %w", err)
+	}
+	return rules.Compile(c.Rules, c.Templates, loc)
+}
+
+func (c *Config) validateRules(fail func(path string, err error)) {
+	if _, err := c.Location(); err != nil {
+		fail("timezone", err)
+	}
+	if _, err := rules.Compile(c.Rules, c.Templates, time.Local); err != nil {
+		fail("rules", err)
+	}
+
+	for i, r := range c.Rules {
+		for _, name := range r.Actions.Route {
+			if _, ok := c.Destination(name); !ok {
+				fail("rules", fmt.Errorf("item %d: unknown destination %q", i+1, name))
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultListen is the address `slackbot serve` listens on by default,
+// usually behind a reverse proxy that terminates TLS.
+const DefaultListen = "127.0.0.1:3000"
+
+// ServeConfig configures the daemon run by `slackbot serve`, which
+// receives button clicks and slash commands from Slack.
+type ServeConfig struct {
+	Listen string `yaml:"listen"` // default 127.0.0.1:3000
+
+	// SigningSecret is the Slack app's signing secret, which proves that
+	// requests come from Slack.
+	SigningSecret string `yaml:"signing_secret"`
+
+	// Queries lists the queries the slash command may run, default all.
+	Queries []string `yaml:"queries"`
+}
+
+// Queries answered by the slash command. All of them are read-only.
+var Queries = []string{"status", "oncall", "maintenance", "mutes"}
+
+// QueryNames returns Queries with its default.
+func (s ServeConfig) QueryNames() []string {
+	if s.Queries == nil {
+		return Queries
+	}
+	return s.Queries
+}
+
+// ListenAddr returns Listen with its default.
+func (s ServeConfig) ListenAddr() string {
+	if s.Listen == "" {
+		return DefaultListen
+	}
+	return s.Listen
+}
+
+// ButtonsConfig adds Acknowledge, Mute and Resolve buttons to alerts.
+// Clicks are handled by `slackbot serve`.
+type ButtonsConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	MuteFor time.Duration `yaml:"mute_for"` // default 1h
+}
+
+func (c *Config) validateServe(fail func(path string, err error)) {
+	if c.Serve.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Serve.Listen); err != nil {
+			fail("serve.listen", fmt.Errorf("want host:port, got %q", c.Serve.Listen))
+		}
+	}
+	for i, name := range c.Serve.Queries {
+		if !slices.Contains(Queries, name) {
+			fail("serve.queries", fmt.Errorf("item %d: unknown query %q, want one of %s", i+1, name, strings.Join(Queries, ", ")))
+		}
+	}
+	if c.Buttons.MuteFor < 0 {
+		fail("buttons.mute_for", fmt.Errorf("must not be negative, got %s", c.Buttons.MuteFor))
+	}
+	if c.Buttons.Enabled && c.Serve.SigningSecret == "" {
+		fail("serve.signing_secret", errors.New("is required to handle buttons"))
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidationError is a semantic problem with a loaded config,
+// located by its key path and, where known, the line that set it.
+type ValidationError struct {
+	Path   string
+	Origin Origin
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Origin.Source == "" {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Origin, e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that URLs are well formed and that every destination
+// reference points to a configured destination. It returns all problems found.
+func (c *Config) Validate() []error {
+	var errs []error
+	fail := func(path string, err error) {
+		origin := c.nearestOrigin(path)
+		errs = append(errs, &ValidationError{Path: path, Origin: origin, Err: err})
+	}
+
+	if c.WebHook != "" {
+		if err := validateURL(c.WebHook); err != nil {
+			fail("webhook", err)
+		}
+	}
+
+	for _, name := range sortedKeys(c.Destinations) {
+		path := "destinations." + name
+		d := c.Destinations[name]
+
+		switch {
+		case name == DefaultDestination && c.WebHook != "":
+			fail(path, errors.New(`conflicts with the top-level webhook, which is the "default" destination`))
+		case d == nil || d.WebHook == "" && d.Token == "":
+			fail(path+".webhook", errors.New("is required"))
+		case d.WebHook != "":
+			if err := validateURL(d.WebHook); err != nil {
+				fail(path+".webhook", err)
+			}
+		}
+		if d != nil && (d.Token == "") != (d.Channel == "") {
+			fail(path, errors.New("token and channel must be set together"))
+		}
+	}
+
+	if len(c.DestinationNames()) == 0 {
+		fail("webhook", errors.New("no destinations configured; set webhook or destinations"))
+	}
+
+	for i, name := range c.DefaultDestinations {
+		if _, ok := c.Destination(name); !ok {
+			fail("default_destinations", fmt.Errorf("item %d: unknown destination %q", i+1, name))
+		}
+	}
+
+	c.PublicIP.validate(fail)
+	c.Cloud.validate(fail)
+	c.Maintenance.validate(fail)
+	c.HTTP.validate(fail)
+	c.Input.validate(fail)
+	c.Message.validate(fail)
+	c.Expire.validate(fail)
+	c.validateWatch(fail)
+	c.validateRules(fail)
+	c.validateOnCall(fail)
+	c.validateServe(fail)
+	if err := c.Interfaces.Filter().Validate(); err != nil {
+		fail("interfaces", err)
+	}
+
+	return errs
+}
+
+// nearestOrigin returns the origin of path or, for a missing value,
+// of the closest enclosing mapping.
+func (c *Config) nearestOrigin(path string) Origin {
+	for {
+		if o, ok := c.Origin(path); ok {
+			return o
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return Origin{}
+		}
+		path = path[:i]
+	}
+}
+
+// validateURL accepts absolute http and https URLs. The URL itself isn't
+// included in the error since it usually contains a token.
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("is not a valid URL")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("must be an http or https URL, got scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("URL has no host")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/maxkulish/slackbot/severity"
+)
+
+// WatchConfig configures `slackbot watch`.
+type WatchConfig struct {
+	PollInterval time.Duration  `yaml:"poll_interval"` // default 1s
+	ContextLines *int           `yaml:"context_lines"` // before and after a match, default 2
+	Patterns     []WatchPattern `yaml:"patterns"`
+}
+
+// WatchPattern maps lines matching Regex to an alert.
+type WatchPattern struct {
+	Regex       string         `yaml:"regex"`
+	Severity    severity.Level `yaml:"severity"`
+	Destination string         `yaml:"destination"` // default: default_destinations
+	Files       []string       `yaml:"files"`       // globs of the files the pattern applies to, default all
+}
+
+// DefaultWatchPatterns are used when no patterns are configured.
+var DefaultWatchPatterns = []WatchPattern{
+	{Regex: `(?i)\b(fatal|panic|critical)\b`, Severity: severity.Critical},
+	{Regex: `(?i)\berror\b`, Severity: severity.Error},
+}
+
+// Context returns the number of context lines around a match.
+func (w WatchConfig) Context() int {
+	if w.ContextLines == nil {
+		return 2
+	}
+	return *w.ContextLines
+}
+
+// PatternsFor returns the patterns that apply to the file at p.
+func (w WatchConfig) PatternsFor(p string) []WatchPattern {
+	patterns := w.Patterns
+	if len(patterns) == 0 {
+		patterns = DefaultWatchPatterns
+	}
+
+	var selected []WatchPattern
+	for _, wp := range patterns {
+		if len(wp.Files) == 0 {
+			selected = append(selected, wp)
+			continue
+		}
+		for _, glob := range wp.Files {
+			if ok, _ := path.Match(glob, p); ok {
+				selected = append(selected, wp)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func (c *Config) validateWatch(fail func(path string, err error)) {
+	if n := c.Watch.Context(); n < 0 {
+		fail("watch.context_lines", fmt.Errorf("must not be negative, got %d", n))
+	}
+
+	for i, wp := range c.Watch.Patterns {
+		item := fmt.Sprintf("item %d", i+1)
+		if _, err := regexp.Compile(wp.Regex); err != nil {
+			fail("watch.patterns", fmt.Errorf("%s: %w", item, err))
+		}
+		if wp.Destination != "" {
+			if _, ok := c.Destination(wp.Destination); !ok {
+				fail("watch.patterns", fmt.Errorf("%s: unknown destination %q", item, wp.Destination))
+			}
+		}
+		for _, glob := range wp.Files {
+			if _, err := path.Match(glob, ""); err != nil {
+				fail("watch.patterns", fmt.Errorf("%s: invalid file pattern %q", item, glob))
+			}
+		}
+	}
+}
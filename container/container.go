@@ -0,0 +1,258 @@
+// Package container detects whether slackbot runs inside a container and,
+// on Kubernetes, which workload the container belongs to.
+package container
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Runtimes
+const (
+	Docker     = "docker"
+	Containerd = "containerd"
+	Podman     = "podman"
+)
+
+// Info describes the container and, on Kubernetes, its pod.
+type Info struct {
+	Runtime     string // docker, containerd or podman, empty if unknown
+	ContainerID string // short form, 12 characters
+	Image       string
+
+	Kubernetes bool
+	Pod        string
+	Namespace  string
+	Node       string
+	Owner      string // e.g. deployment/web or statefulset/db
+}
+
+// Detector reads the container's view of the system below Root, which tests
+// point at a fixture directory. Downward-API environment variables are read
+// with Getenv.
+type Detector struct {
+	Root       string              // default /
+	Getenv     func(string) string // default os.Getenv
+	LabelsFile string              // downward API labels volume, default /etc/podinfo/labels
+}
+
+const serviceAccountDir = "var/run/secrets/kubernetes.io/serviceaccount"
+
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// runtimePatterns match the forms a runtime's containers take in cgroup
+// paths and hostname mount sources, such as /docker/<id> and
+// docker-<id>.scope, rather than any path that names the runtime: a host
+// running docker.service isn't a container.
+var runtimePatterns = []struct {
+	runtime string
+	re      *regexp.Regexp
+}{
+	{Docker, regexp.MustCompile(`(?:/docker/|docker-|/docker/containers/)([0-9a-f]{64})`)},
+	{Containerd, regexp.MustCompile(`(?:cri-containerd-|/containerd/|/io\.containerd\.\S*/)([0-9a-f]{64})`)},
+	{Podman, regexp.MustCompile(`(?:libpod-|/overlay-containers/)([0-9a-f]{64})`)},
+}
+
+// kubepodsPattern matches the kubelet's pod cgroups, e.g.
+// /kubepods/burstable/pod<uid> or /kubepods.slice/kubepods-burstable.slice.
+var kubepodsPattern = regexp.MustCompile(`(?m)/kubepods(?:[./-]|$)`)
+
+// Detect returns nil when slackbot doesn't run in a container.
+func (d Detector) Detect() (*Info, error) {
+	d = d.withDefaults()
+	info := &Info{}
+	inContainer := false
+
+	switch {
+	case d.exists(".dockerenv"):
+		info.Runtime, inContainer = Docker, true
+	case d.exists("run/.containerenv"):
+		info.Runtime, inContainer = Podman, true
+	}
+
+	// cgroup v1 names the runtime and container in the cgroup path; with
+	// cgroup v2 they show up in the source of the /etc/hostname bind mount
+	cgroup, err := d.read("proc/self/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	mountinfo, err := d.read("proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, data := range []string{cgroup, hostnameMount(mountinfo)} {
+		for _, p := range runtimePatterns {
+			m := p.re.FindStringSubmatch(data)
+			if m == nil {
+				continue
+			}
+			if info.Runtime == "" {
+				info.Runtime = p.runtime
+			}
+			if info.ContainerID == "" {
+				info.ContainerID = m[1][:12]
+			}
+			inContainer = true
+			break
+		}
+		if kubepodsPattern.MatchString(data) {
+			info.Kubernetes, inContainer = true, true
+		}
+		if info.ContainerID == "" && inContainer {
+			if id := containerIDPattern.FindString(data); id != "" {
+				info.ContainerID = id[:12]
+			}
+		}
+	}
+
+	if d.exists(serviceAccountDir) || d.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		info.Kubernetes, inContainer = true, true
+	}
+
+	if !inContainer {
+		return nil, nil
+	}
+
+	info.Image = d.Getenv("CONTAINER_IMAGE")
+	if info.Kubernetes {
+		if err := d.detectPod(info); err != nil {
+			return info, err
+		}
+	}
+
+	return info, nil
+}
+
+// hostnameMount returns the source of the /etc/hostname mount. Container
+// runtimes bind-mount it from a per-container directory, while a host
+// running containers itself has no such mount.
+func hostnameMount(mountinfo string) string {
+	for _, line := range strings.Split(mountinfo, "\n") {
+		// 1282 1268 254:1 /var/lib/docker/containers/<id>/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw
+		fields := strings.Fields(line)
+		if len(fields) > 4 && fields[4] == "/etc/hostname" {
+			return fields[3]
+		}
+	}
+	return ""
+}
+
+func (d Detector) withDefaults() Detector {
+	if d.Root == "" {
+		d.Root = "/"
+	}
+	if d.Getenv == nil {
+		d.Getenv = os.Getenv
+	}
+	if d.LabelsFile == "" {
+		d.LabelsFile = "/etc/podinfo/labels"
+	}
+	return d
+}
+
+// detectPod fills the pod details from downward-API environment variables,
+// falling back to the service account namespace and the hostname.
+func (d Detector) detectPod(info *Info) error {
+	info.Pod = firstNonEmpty(d.Getenv("POD_NAME"), d.Getenv("HOSTNAME"))
+	info.Node = d.Getenv("NODE_NAME")
+
+	info.Namespace = d.Getenv("POD_NAMESPACE")
+	if info.Namespace == "" {
+		ns, err := d.read(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return err
+		}
+		info.Namespace = strings.TrimSpace(ns)
+	}
+
+	labels, err := d.readLabels()
+	if err != nil {
+		return err
+	}
+	info.Owner = owner(info.Pod, labels, d.Getenv("DEPLOYMENT_NAME"))
+
+	return nil
+}
+
+// readLabels parses the downward API labels file, one key="value" per line.
+func (d Detector) readLabels() (map[string]string, error) {
+	data, err := d.read(strings.TrimPrefix(d.LabelsFile, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	for _, line := range strings.Split(data, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+var (
+	// web-7d9f8c6b5-x2kq9: deployment, ReplicaSet hash, pod suffix
+	deploymentPodPattern = regexp.MustCompile(`^(.+)-[a-z0-9]{6,10}-[a-z0-9]{5}$`)
+	// db-0: StatefulSet ordinal
+	statefulSetPodPattern = regexp.MustCompile(`^(.+)-[0-9]+$`)
+)
+
+// owner works out the workload that owns a pod. The pod-template-hash label
+// identifies a Deployment precisely; otherwise the pod name is parsed.
+func owner(pod string, labels map[string]string, deployment string) string {
+	if deployment != "" {
+		return "deployment/" + deployment
+	}
+
+	if hash := labels["pod-template-hash"]; hash != "" {
+		if i := strings.LastIndex(pod, "-"+hash+"-"); i > 0 {
+			return "deployment/" + pod[:i]
+		}
+	}
+	if _, ok := labels["statefulset.kubernetes.io/pod-name"]; ok {
+		if m := statefulSetPodPattern.FindStringSubmatch(pod); m != nil {
+			return "statefulset/" + m[1]
+		}
+	}
+	if len(labels) == 0 {
+		if m := deploymentPodPattern.FindStringSubmatch(pod); m != nil {
+			return "deployment/" + m[1]
+		}
+	}
+
+	return ""
+}
+
+func (d Detector) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(d.Root, name))
+	return err == nil
+}
+
+// read returns the contents of a file below Root; a missing file is empty.
+func (d Detector) read(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(d.Root, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
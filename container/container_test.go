@@ -0,0 +1,142 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const containerID = "3f4e8c1b9a2d7e6f5c4b3a29180716253443526170898a7b6c5d4e3f2a1b0c9d"
+
+// fixture builds a root directory from a map of relative paths to contents.
+func fixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		desc  string
+		files map[string]string
+		env   map[string]string
+		want  *Info
+	}{
+		{
+			desc: "plain host",
+			files: map[string]string{
+				"proc/self/cgroup":    "0::/user.slice/user-1000.slice/session-3.scope\n",
+				"proc/self/mountinfo": "29 1 254:1 / / rw,relatime shared:1 - ext4 /dev/vda1 rw\n",
+			},
+			want: nil,
+		},
+		{
+			desc: "host running docker",
+			files: map[string]string{
+				"proc/self/cgroup":    "12:memory:/system.slice/docker.service\n0::/system.slice/docker.service\n",
+				"proc/self/mountinfo": "29 1 254:1 / / rw,relatime shared:1 - ext4 /dev/vda1 rw\n512 29 0:48 / /var/lib/docker/overlay2/" + containerID + "/merged rw - overlay overlay rw\n",
+			},
+			want: nil,
+		},
+		{
+			desc: "docker with the systemd cgroup driver",
+			files: map[string]string{
+				"proc/self/cgroup": "0::/system.slice/docker-" + containerID + ".scope\n",
+			},
+			want: &Info{Runtime: Docker, ContainerID: containerID[:12]},
+		},
+		{
+			desc: "podman",
+			files: map[string]string{
+				"proc/self/cgroup": "0::/machine.slice/libpod-" + containerID + ".scope/container\n",
+			},
+			want: &Info{Runtime: Podman, ContainerID: containerID[:12]},
+		},
+		{
+			desc: "docker with cgroup v1",
+			files: map[string]string{
+				".dockerenv":       "",
+				"proc/self/cgroup": "12:memory:/docker/" + containerID + "\n",
+			},
+			env:  map[string]string{"CONTAINER_IMAGE": "nginx:1.25"},
+			want: &Info{Runtime: Docker, ContainerID: containerID[:12], Image: "nginx:1.25"},
+		},
+		{
+			desc: "docker with cgroup v2",
+			files: map[string]string{
+				"proc/self/cgroup":    "0::/\n",
+				"proc/self/mountinfo": "1282 1268 254:1 /var/lib/docker/containers/" + containerID + "/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw\n",
+			},
+			want: &Info{Runtime: Docker, ContainerID: containerID[:12]},
+		},
+		{
+			desc: "kubernetes with downward API",
+			files: map[string]string{
+				"proc/self/cgroup":               "0::/kubepods.slice/kubepods-burstable.slice/cri-containerd-" + containerID + ".scope\n",
+				serviceAccountDir + "/namespace": "ignored",
+				"etc/podinfo/labels":             "app=\"web\"\npod-template-hash=\"7d9f8c6b5\"\n",
+			},
+			env: map[string]string{
+				"KUBERNETES_SERVICE_HOST": "10.96.0.1",
+				"POD_NAME":                "web-7d9f8c6b5-x2kq9",
+				"POD_NAMESPACE":           "shop",
+				"NODE_NAME":               "node-a",
+				"CONTAINER_IMAGE":         "registry.example.com/web:2.3.1",
+			},
+			want: &Info{
+				Runtime: Containerd, ContainerID: containerID[:12], Image: "registry.example.com/web:2.3.1",
+				Kubernetes: true, Pod: "web-7d9f8c6b5-x2kq9", Namespace: "shop", Node: "node-a", Owner: "deployment/web",
+			},
+		},
+		{
+			desc: "kubernetes without downward API",
+			files: map[string]string{
+				serviceAccountDir + "/namespace": "batch\n",
+			},
+			env:  map[string]string{"HOSTNAME": "db-2"},
+			want: &Info{Kubernetes: true, Pod: "db-2", Namespace: "batch"},
+		},
+		{
+			desc: "statefulset from labels",
+			files: map[string]string{
+				serviceAccountDir + "/namespace": "data",
+				"etc/podinfo/labels":             "statefulset.kubernetes.io/pod-name=\"db-2\"\n",
+			},
+			env:  map[string]string{"HOSTNAME": "db-2"},
+			want: &Info{Kubernetes: true, Pod: "db-2", Namespace: "data", Owner: "statefulset/db"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			root := fixture(t, c.files)
+			d := Detector{Root: root, Getenv: env(c.env)}
+
+			got, err := d.Detect()
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+
+			switch {
+			case c.want == nil && got != nil:
+				t.Errorf("Detect() = %+v, want nil", got)
+			case c.want != nil && (got == nil || *got != *c.want):
+				t.Errorf("Detect() = %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Package heartbeat keeps track of pings from jobs that are expected to
+// run regularly, so that a job that stops running is noticed.
+package heartbeat
+
+import (
+	"sort"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+// stateDoc names the state document with the heartbeats.
+const stateDoc = "heartbeats"
+
+// Heartbeat is a job that pings at least every Expect.
+type Heartbeat struct {
+	Name   string        `json:"name"`
+	Expect time.Duration `json:"expect"`
+	Last   time.Time     `json:"last"` // last ping
+
+	// Overdue is when the job was reported overdue, until it's reported
+	// back. Silence is how long it went without a ping by then.
+	Overdue time.Time     `json:"overdue,omitempty"`
+	Silence time.Duration `json:"silence,omitempty"`
+}
+
+// Due returns when h becomes overdue without another ping.
+func (h Heartbeat) Due() time.Time {
+	return h.Last.Add(h.Expect)
+}
+
+// Late reports whether h is overdue by now and hasn't been reported yet.
+func (h Heartbeat) Late(now time.Time) bool {
+	return h.Overdue.IsZero() && now.After(h.Due())
+}
+
+// Back reports whether h pinged again after it was reported overdue.
+func (h Heartbeat) Back() bool {
+	return !h.Overdue.IsZero() && h.Last.After(h.Overdue)
+}
+
+// Store keeps heartbeats in the state store.
+type Store struct {
+	State *state.Store
+}
+
+// Ping records a ping of the named heartbeat at now. A zero expect keeps
+// the interval of earlier pings; ok is false if there were none.
+func (s Store) Ping(name string, expect time.Duration, now time.Time) (h Heartbeat, ok bool, err error) {
+	var beats []Heartbeat
+	err = s.State.Update(stateDoc, &beats, func() error {
+		i := find(beats, name)
+		if i < 0 {
+			if expect <= 0 {
+				return nil
+			}
+			beats = append(beats, Heartbeat{Name: name})
+			i = len(beats) - 1
+		}
+		if expect > 0 {
+			beats[i].Expect = expect
+		}
+		if b := beats[i]; !b.Overdue.IsZero() && !b.Last.After(b.Overdue) {
+			beats[i].Silence = now.Sub(b.Last)
+		}
+		beats[i].Last = now
+		h, ok = beats[i], true
+		return nil
+	})
+	return h, ok, err
+}
+
+// List returns the heartbeats by name.
+func (s Store) List() ([]Heartbeat, error) {
+	var beats []Heartbeat
+	if err := s.State.Load(stateDoc, &beats); err != nil {
+		return nil, err
+	}
+	sort.Slice(beats, func(i, j int) bool { return beats[i].Name < beats[j].Name })
+	return beats, nil
+}
+
+// MarkOverdue records that the named heartbeat was reported overdue at
+// now, or with a zero now, that it was reported back.
+func (s Store) MarkOverdue(name string, now time.Time) error {
+	var beats []Heartbeat
+	return s.State.Update(stateDoc, &beats, func() error {
+		if i := find(beats, name); i >= 0 {
+			beats[i].Overdue, beats[i].Silence = now, 0
+		}
+		return nil
+	})
+}
+
+// Remove forgets the named heartbeat and reports whether there was one.
+func (s Store) Remove(name string) (bool, error) {
+	var (
+		beats []Heartbeat
+		found bool
+	)
+	err := s.State.Update(stateDoc, &beats, func() error {
+		if i := find(beats, name); i >= 0 {
+			beats = append(beats[:i], beats[i+1:]...)
+			found = true
+		}
+		return nil
+	})
+	return found, err
+}
+
+func find(beats []Heartbeat, name string) int {
+	for i, h := range beats {
+		if h.Name == name {
+			return i
+		}
+	}
+	return -1
+}
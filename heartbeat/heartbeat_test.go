@@ -0,0 +1,57 @@
+package heartbeat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+func TestStore(t *testing.T) {
+	st, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Store{State: st}
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	if _, ok, err := s.Ping("backup", 0, start); err != nil || ok {
+		t.Fatalf("first Ping() without expect = %v, %v, want not ok", ok, err)
+	}
+	if _, _, err := s.Ping("backup", time.Hour, start); err != nil {
+		t.Fatal(err)
+	}
+	if h, ok, _ := s.Ping("backup", 0, start.Add(50*time.Minute)); !ok || h.Expect != time.Hour {
+		t.Fatalf("Ping() = %+v, %v, want the earlier interval kept", h, ok)
+	}
+
+	late := start.Add(2 * time.Hour)
+	list, _ := s.List()
+	if len(list) != 1 || !list[0].Late(late) || list[0].Late(start.Add(time.Hour)) {
+		t.Fatalf("List() = %+v, want backup late at %s only", list, late)
+	}
+	if err := s.MarkOverdue("backup", late); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.List(); list[0].Late(late.Add(time.Hour)) || list[0].Back() {
+		t.Error("a heartbeat reported overdue should be neither late again nor back")
+	}
+
+	back := start.Add(3 * time.Hour)
+	if h, _, _ := s.Ping("backup", 0, back); !h.Back() || h.Silence != back.Sub(start.Add(50*time.Minute)) {
+		t.Errorf("Ping() after overdue = %+v, want it back after 2h10m", h)
+	}
+	if err := s.MarkOverdue("backup", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.List(); list[0].Back() || list[0].Late(back.Add(30*time.Minute)) {
+		t.Errorf("List() = %+v, want backup on time again", list)
+	}
+
+	if found, _ := s.Remove("backup"); !found {
+		t.Error("Remove() didn't find backup")
+	}
+	if list, _ := s.List(); len(list) != 0 {
+		t.Errorf("List() after Remove = %+v", list)
+	}
+}
@@ -0,0 +1,76 @@
+// Package history remembers the last alerts sent from this host, for
+// status queries from Slack.
+package history
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/state"
+)
+
+// stateDoc names the state document with the alerts.
+const stateDoc = "history"
+
+// Keep is how many alerts are remembered.
+const Keep = 50
+
+// maxSummary bounds the length of a summary, in characters.
+const maxSummary = 120
+
+// Entry is an alert that was sent.
+type Entry struct {
+	Time     time.Time      `json:"time"`
+	Severity severity.Level `json:"severity"`
+	Source   string         `json:"source"`
+	Summary  string         `json:"summary"` // first line of the text
+	Route    []string       `json:"route"`
+}
+
+// Store keeps the last alerts in the state store.
+type Store struct {
+	State *state.Store
+}
+
+// Summary returns the first line of text that isn't empty, shortened.
+func Summary(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > maxSummary {
+			line = string([]rune(line)[:maxSummary-1]) + "…"
+		}
+		return line
+	}
+	return ""
+}
+
+// Record adds e, forgetting the oldest alerts beyond Keep.
+func (s Store) Record(e Entry) error {
+	var entries []Entry
+	return s.State.Update(stateDoc, &entries, func() error {
+		entries = append(entries, e)
+		if len(entries) > Keep {
+			entries = entries[len(entries)-Keep:]
+		}
+		return nil
+	})
+}
+
+// Recent returns the last n alerts, newest first.
+func (s Store) Recent(n int) ([]Entry, error) {
+	var entries []Entry
+	if err := s.State.Load(stateDoc, &entries); err != nil {
+		return nil, err
+	}
+
+	recent := make([]Entry, 0, min(n, len(entries)))
+	for i := len(entries) - 1; i >= 0 && len(recent) < n; i-- {
+		recent = append(recent, entries[i])
+	}
+	return recent, nil
+}
@@ -0,0 +1,52 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/state"
+)
+
+func TestStore(t *testing.T) {
+	st, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Store{State: st}
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	for i := range Keep + 5 {
+		e := Entry{Time: start.Add(time.Duration(i) * time.Minute), Severity: severity.Error, Summary: fmt.Sprint("alert ", i)}
+		if err := s.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recent, err := s.Recent(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range recent {
+		got = append(got, e.Summary)
+	}
+	if want := fmt.Sprintf("alert %d,alert %d,alert %d", Keep+4, Keep+3, Keep+2); strings.Join(got, ",") != want {
+		t.Errorf("Recent(3) = %v, want %s", got, want)
+	}
+
+	if all, _ := s.Recent(1000); len(all) != Keep || all[Keep-1].Summary != "alert 5" {
+		t.Errorf("Recent() kept %d alerts, want the last %d", len(all), Keep)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	if got := Summary("\n\n  disk /var at 91%\nmore details"); got != "disk /var at 91%" {
+		t.Errorf("Summary() = %q", got)
+	}
+	if got := Summary(strings.Repeat("é", 200)); len([]rune(got)) != maxSummary || !strings.HasSuffix(got, "…") {
+		t.Errorf("Summary() of a long line = %q", got)
+	}
+}
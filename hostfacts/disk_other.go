@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package hostfacts
+
+import "errors"
+
+func diskUsage(string) (total, free uint64, err error) {
+	return 0, 0, errors.New("not supported on this platform")
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package hostfacts
+
+import "syscall"
+
+// diskUsage returns the size of the filesystem holding path and the space
+// available to unprivileged users, in bytes.
+func diskUsage(path string) (total, free uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+
+	bsize := uint64(st.Bsize)
+	return st.Blocks * bsize, st.Bavail * bsize, nil
+}
@@ -0,0 +1,210 @@
+// Package hostfacts reads a summary of the host's state from /proc and
+// /etc/os-release: distribution, kernel, uptime, load, memory and disk usage.
+package hostfacts
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Facts is a snapshot of the host's state. Values that couldn't be read
+// are left at zero.
+type Facts struct {
+	Distro       string
+	Kernel       string
+	Uptime       time.Duration
+	Load         [3]float64 // 1, 5 and 15 minute load averages
+	MemTotal     uint64     // bytes
+	MemAvailable uint64     // bytes
+	DiskPath     string
+	DiskTotal    uint64 // bytes
+	DiskFree     uint64 // bytes available to unprivileged users
+}
+
+// MemUsed returns the memory in use, excluding reclaimable caches.
+func (f Facts) MemUsed() uint64 {
+	return f.MemTotal - min(f.MemAvailable, f.MemTotal)
+}
+
+// DiskUsed returns the space in use on DiskPath.
+func (f Facts) DiskUsed() uint64 {
+	return f.DiskTotal - min(f.DiskFree, f.DiskTotal)
+}
+
+// Reader reads facts from the given locations, which tests point at fixtures.
+type Reader struct {
+	ProcDir   string // default /proc
+	OSRelease string // default /etc/os-release
+	DiskPath  string // filesystem to report, default /
+}
+
+// Read collects every fact it can. The error lists the facts that
+// couldn't be read; the returned Facts are still usable.
+func (r Reader) Read() (Facts, error) {
+	r = r.withDefaults()
+	f := Facts{DiskPath: r.DiskPath}
+
+	var errs []error
+	collect := func(name string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	var err error
+	f.Distro, err = readDistro(r.OSRelease)
+	collect("distro", err)
+	f.Kernel, err = readTrimmed(filepath.Join(r.ProcDir, "sys", "kernel", "osrelease"))
+	collect("kernel", err)
+	f.Uptime, err = readUptime(filepath.Join(r.ProcDir, "uptime"))
+	collect("uptime", err)
+	f.Load, err = readLoad(filepath.Join(r.ProcDir, "loadavg"))
+	collect("load", err)
+	f.MemTotal, f.MemAvailable, err = readMeminfo(filepath.Join(r.ProcDir, "meminfo"))
+	collect("memory", err)
+	f.DiskTotal, f.DiskFree, err = diskUsage(r.DiskPath)
+	collect("disk", err)
+
+	return f, errors.Join(errs...)
+}
+
+func (r Reader) withDefaults() Reader {
+	if r.ProcDir == "" {
+		r.ProcDir = "/proc"
+	}
+	if r.OSRelease == "" {
+		r.OSRelease = "/etc/os-release"
+	}
+	if r.DiskPath == "" {
+		r.DiskPath = "/"
+	}
+	return r
+}
+
+func readTrimmed(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readDistro returns PRETTY_NAME from os-release, falling back to NAME VERSION.
+func readDistro(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	values := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'"`)
+		}
+		values[key] = value
+	}
+
+	if v := values["PRETTY_NAME"]; v != "" {
+		return v, nil
+	}
+	if v := strings.TrimSpace(values["NAME"] + " " + values["VERSION"]); v != "" {
+		return v, nil
+	}
+	return "", errors.New("no PRETTY_NAME or NAME")
+}
+
+// readUptime parses the first field of /proc/uptime, in seconds.
+func readUptime(path string) (time.Duration, error) {
+	data, err := readTrimmed(path)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		return 0, errors.New("empty file")
+	}
+
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// readLoad parses the first three fields of /proc/loadavg.
+func readLoad(path string) ([3]float64, error) {
+	var load [3]float64
+
+	data, err := readTrimmed(path)
+	if err != nil {
+		return load, err
+	}
+
+	fields := strings.Fields(data)
+	if len(fields) < 3 {
+		return load, fmt.Errorf("unexpected format %q", data)
+	}
+
+	for i := range load {
+		if load[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return load, err
+		}
+	}
+	return load, nil
+}
+
+// readMeminfo returns MemTotal and MemAvailable from /proc/meminfo in bytes.
+func readMeminfo(path string) (total, available uint64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// MemTotal:       16314436 kB
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		fields := strings.Fields(rest)
+		if !ok || len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			n *= 1024
+		}
+		values[key] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	total, ok := values["MemTotal"]
+	if !ok {
+		return 0, 0, errors.New("no MemTotal")
+	}
+
+	available, ok = values["MemAvailable"]
+	if !ok {
+		// Kernels before 3.14
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	return total, available, nil
+}
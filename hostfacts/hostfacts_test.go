@@ -0,0 +1,55 @@
+package hostfacts
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	r := Reader{
+		ProcDir:   filepath.Join("testdata", "proc"),
+		OSRelease: filepath.Join("testdata", "etc", "os-release"),
+		DiskPath:  t.TempDir(),
+	}
+
+	f, err := r.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	if f.Distro != "Ubuntu 22.04.3 LTS" {
+		t.Errorf("Distro = %q", f.Distro)
+	}
+	if f.Kernel != "5.15.0-91-generic" {
+		t.Errorf("Kernel = %q", f.Kernel)
+	}
+	if want := 1054921*time.Second + 370*time.Millisecond; f.Uptime.Round(time.Millisecond) != want {
+		t.Errorf("Uptime = %v, want %v", f.Uptime, want)
+	}
+	if f.Load != [3]float64{0.52, 0.48, 0.40} {
+		t.Errorf("Load = %v", f.Load)
+	}
+	if f.MemTotal != 8048576*1024 || f.MemUsed() != 4024288*1024 {
+		t.Errorf("MemTotal = %d, MemUsed = %d", f.MemTotal, f.MemUsed())
+	}
+	if f.DiskTotal == 0 || f.DiskUsed() > f.DiskTotal {
+		t.Errorf("DiskTotal = %d, DiskUsed = %d", f.DiskTotal, f.DiskUsed())
+	}
+}
+
+func TestReadPartial(t *testing.T) {
+	r := Reader{
+		ProcDir:   filepath.Join("testdata", "missing"),
+		OSRelease: filepath.Join("testdata", "etc", "os-release"),
+		DiskPath:  t.TempDir(),
+	}
+
+	f, err := r.Read()
+	if err == nil {
+		t.Fatal("expected an error for the missing /proc files")
+	}
+	if f.Distro != "Ubuntu 22.04.3 LTS" {
+		t.Errorf("facts that could be read should still be returned, Distro = %q", f.Distro)
+	}
+}
@@ -0,0 +1,266 @@
+// Package input reads the message text given to slackbot. It accepts lines
+// of any length, keeps the end of input larger than a limit, tells text from
+// binary data and transcodes UTF-16 and Latin-1 text to UTF-8.
+package input
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// DefaultMaxSize is the most input kept by default.
+const DefaultMaxSize = 8 << 20
+
+// sniffLen is how much of the input decides whether it's binary.
+const sniffLen = 8 << 10
+
+// Encodings of text input
+const (
+	UTF8    = "utf-8"
+	UTF16LE = "utf-16le"
+	UTF16BE = "utf-16be"
+	Latin1  = "latin-1"
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// ErrTooLarge is returned, along with the start of the input, for binary
+// input larger than the limit, which can't be shortened like text.
+var ErrTooLarge = errors.New("binary input is larger than the limit")
+
+// Input is what was read.
+type Input struct {
+	// Text is the input as UTF-8, empty for binary input.
+	Text string
+
+	// Data is the binary input as read.
+	Data []byte
+
+	Binary      bool
+	ContentType string // detected MIME type, e.g. application/gzip
+	Encoding    string // encoding of text input, one of the constants above
+
+	// Size is the size of the whole input, Skipped how much of its start
+	// was left out to stay under the limit.
+	Size    int64
+	Skipped int64
+}
+
+// Read reads r to its end and keeps at most maxSize bytes, or DefaultMaxSize
+// if maxSize isn't positive. Text larger than that keeps its end, starting
+// at a line boundary.
+func Read(r io.Reader, maxSize int64) (*Input, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	in := &Input{ContentType: http.DetectContentType(head)}
+	in.Binary, in.Encoding = sniff(head)
+
+	if in.Binary {
+		// Both ends of a file are needed to make sense of it
+		data, err := io.ReadAll(io.LimitReader(r, maxSize-int64(len(head))+1))
+		if err != nil {
+			return nil, err
+		}
+		in.Data = append(head, data...)
+		in.Size = int64(len(in.Data))
+		if in.Size > maxSize {
+			// Drain the rest so that the writer doesn't fail and the
+			// size is known
+			rest, err := io.Copy(io.Discard, r)
+			if err != nil {
+				return nil, err
+			}
+			in.Size += rest
+			return in, fmt.Errorf("%w of %s", ErrTooLarge, FormatSize(maxSize))
+		}
+		return in, nil
+	}
+
+	data, size, err := readTail(r, head, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	in.Size = size
+
+	data = bytes.TrimPrefix(data, bomUTF8)
+	switch in.Encoding {
+	case UTF16LE, UTF16BE:
+		data = bytes.TrimPrefix(bytes.TrimPrefix(data, bomUTF16LE), bomUTF16BE)
+	}
+
+	if size > maxSize {
+		data = lineStart(data, in.Encoding)
+		in.Skipped = size - int64(len(data))
+	}
+
+	switch in.Encoding {
+	case UTF16LE, UTF16BE:
+		in.Text = decodeUTF16(data, in.Encoding == UTF16BE)
+	default:
+		if utf8.Valid(data) {
+			in.Text = string(data)
+		} else {
+			in.Encoding = Latin1
+			in.Text = decodeLatin1(data)
+		}
+	}
+
+	return in, nil
+}
+
+// readTail returns the last maxSize bytes of head followed by the rest of
+// r, and the size of all of it.
+func readTail(r io.Reader, head []byte, maxSize int64) ([]byte, int64, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, max(2*len(head), 64<<10)))
+	buf.Write(head)
+	size := int64(len(head))
+
+	chunk := make([]byte, 64<<10)
+	for {
+		n, err := r.Read(chunk)
+		buf.Write(chunk[:n])
+		size += int64(n)
+
+		// Drop the start once it's far enough behind, so that copying
+		// stays linear in the size of the input
+		if int64(buf.Len()) > 2*maxSize {
+			keep := buf.Bytes()[int64(buf.Len())-maxSize:]
+			buf = bytes.NewBuffer(append(make([]byte, 0, 2*maxSize), keep...))
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	data := buf.Bytes()
+	if int64(len(data)) > maxSize {
+		data = data[int64(len(data))-maxSize:]
+	}
+	return data, size, nil
+}
+
+// sniff tells binary data from text by its first bytes and guesses the
+// encoding of text.
+func sniff(head []byte) (binary bool, encoding string) {
+	switch {
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return false, UTF16LE
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return false, UTF16BE
+	case bytes.IndexByte(head, 0) >= 0:
+		return true, ""
+	}
+
+	controls := 0
+	for _, b := range head {
+		switch {
+		case b == '\t', b == '\n', b == '\r', b == '\f', b == '\b', b == 0x1b:
+		case b < 0x20, b == 0x7f:
+			controls++
+		}
+	}
+	if len(head) > 0 && controls*10 > len(head) {
+		return true, ""
+	}
+
+	return false, UTF8
+}
+
+// lineStart drops the partial line at the start of data, if there is a
+// later line, or else just the partial character.
+func lineStart(data []byte, encoding string) []byte {
+	switch encoding {
+	case UTF16LE, UTF16BE:
+		if len(data)%2 == 1 {
+			data = data[1:]
+		}
+		nl := []byte{'\n', 0}
+		if encoding == UTF16BE {
+			nl = []byte{0, '\n'}
+		}
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == nl[0] && data[i+1] == nl[1] {
+				return data[i+2:]
+			}
+		}
+		return data
+	default:
+		if i := bytes.IndexByte(data, '\n'); i >= 0 && i < len(data)-1 {
+			return data[i+1:]
+		}
+		for len(data) > 0 && !utf8.RuneStart(data[0]) {
+			data = data[1:]
+		}
+		return data
+	}
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// windows1252 maps the bytes 0x80-0x9f, control characters in Latin-1, to
+// the characters Windows puts there, which is what such bytes usually mean.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+func decodeLatin1(data []byte) string {
+	var b bytes.Buffer
+	b.Grow(len(data) + len(data)/8)
+	for _, c := range data {
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+		case c < 0xa0:
+			b.WriteRune(windows1252[c-0x80])
+		default:
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// FormatSize formats a size in bytes for people, e.g. 1.2 MiB.
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
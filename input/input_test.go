@@ -0,0 +1,97 @@
+package input
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	long := `{"msg":"` + strings.Repeat("x", 1<<20) + `"}`
+
+	cases := []struct {
+		desc     string
+		in       []byte
+		text     string
+		encoding string
+	}{
+		{"empty", nil, "", UTF8},
+		{"long line", []byte(long + "\n"), long + "\n", UTF8},
+		{"utf-8 bom", []byte("\xef\xbb\xbfgrüße"), "grüße", UTF8},
+		{"utf-16le", []byte("\xff\xfeo\x00k\x00 \x00\xac\x20\n\x00"), "ok €\n", UTF16LE},
+		{"utf-16be", []byte("\xfe\xff\x00o\x00k\xd8\x3d\xde\x00"), "ok😀", UTF16BE},
+		{"latin-1", []byte("Gr\xfc\xdfe \x80 5"), "Grüße € 5", Latin1},
+		{"ansi", []byte("\x1b[31mFAIL\x1b[0m\r\n"), "\x1b[31mFAIL\x1b[0m\r\n", UTF8},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			in, err := Read(bytes.NewReader(c.in), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if in.Binary || in.Text != c.text || in.Encoding != c.encoding || in.Skipped != 0 {
+				t.Errorf("Read() = binary %v, %s %.40q, skipped %d, want %s %.40q",
+					in.Binary, in.Encoding, in.Text, in.Skipped, c.encoding, c.text)
+			}
+		})
+	}
+}
+
+func TestReadKeepsEnd(t *testing.T) {
+	var b strings.Builder
+	for b.Len() < 100_000 {
+		b.WriteString("progress line\n")
+	}
+	b.WriteString("ERROR: failed\n")
+
+	in, err := Read(strings.NewReader(b.String()), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(in.Text, "progress line\n") || !strings.HasSuffix(in.Text, "ERROR: failed\n") || len(in.Text) > 1000 {
+		t.Errorf("Text = %q, want whole lines from the end", in.Text)
+	}
+	if in.Size != int64(b.Len()) || in.Skipped != in.Size-int64(len(in.Text)) {
+		t.Errorf("Size = %d, Skipped = %d, want %d and the rest", in.Size, in.Skipped, b.Len())
+	}
+}
+
+func TestReadBinary(t *testing.T) {
+	gz := append([]byte{0x1f, 0x8b, 0x08, 0x00}, bytes.Repeat([]byte{0x00, 0x03}, 100)...)
+
+	in, err := Read(bytes.NewReader(gz), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !in.Binary || in.ContentType != "application/x-gzip" || !bytes.Equal(in.Data, gz) || in.Text != "" {
+		t.Errorf("Read() = %+v, want binary gzip data", in)
+	}
+
+	in, err = Read(bytes.NewReader(gz), 100)
+	if !errors.Is(err, ErrTooLarge) || in == nil || in.Size != int64(len(gz)) {
+		t.Errorf("Read() over the limit = %v, %v, want ErrTooLarge with the size", in, err)
+	}
+
+	if in, _ := Read(strings.NewReader("\x01\x02\x03\x04abc"), 0); !in.Binary {
+		t.Errorf("control characters should make input binary")
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	got := HexDump([]byte("slackbot\x00\x01 hex dump test"), 20)
+	want := "00000000  73 6c 61 63 6b 62 6f 74  00 01 20 68 65 78 20 64  |slackbot.. hex d|\n" +
+		"00000010  75 6d 70 20                                       |ump |"
+	if got != want {
+		t.Errorf("HexDump() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 8 << 20: "8.0 MiB"} {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
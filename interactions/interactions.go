@@ -0,0 +1,205 @@
+// Package interactions handles clicks on the buttons slackbot adds to
+// alerts: acknowledging an alert, muting its repeats and resolving it.
+package interactions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/mute"
+	"github.com/maxkulish/slackbot/slack"
+)
+
+// Action IDs of the buttons
+const (
+	ActionAcknowledge = "acknowledge"
+	ActionMute        = "mute"
+	ActionResolve     = "resolve"
+)
+
+// statusBlockID identifies the context block that tells who did what.
+const statusBlockID = "slackbot_status"
+
+// DefaultMuteFor is how long the mute button mutes an alert by default.
+const DefaultMuteFor = time.Hour
+
+// Buttons returns the option that adds the buttons to an alert from host.
+// Key identifies repeats of the alert, see mute.Key.
+func Buttons(host, key string, muteFor time.Duration) slack.MessageOption {
+	if muteFor <= 0 {
+		muteFor = DefaultMuteFor
+	}
+	value := url.Values{"key": {key}, "host": {host}, "for": {muteFor.String()}}.Encode()
+
+	return slack.WithActions(
+		slack.Button("Acknowledge", ActionAcknowledge, value, "primary"),
+		slack.Button("Mute "+FormatDuration(muteFor), ActionMute, value, ""),
+		slack.Button("Resolve", ActionResolve, value, ""),
+	)
+}
+
+// FormatDuration formats d without zero units, e.g. 1h or 1h30m.
+func FormatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// Handler serves the interactivity request URL of a Slack app. It checks
+// that requests are signed with the app's signing secret, records mutes
+// and updates the message to show who acted on it.
+type Handler struct {
+	SigningSecret string
+	Mutes         mute.Store
+	Client        *http.Client     // for response URLs
+	Location      *time.Location   // for times in messages, default local
+	Now           func() time.Time // default time.Now
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	form, err := slack.ReadRequest(h.SigningSecret, r, h.now())
+	if err != nil {
+		log.Printf("interactions: %v", err)
+		http.Error(w, "invalid request", http.StatusUnauthorized)
+		return
+	}
+
+	var in slack.Interaction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if in.Type != "block_actions" {
+		// Nothing else is sent to slackbot's buttons
+		return
+	}
+
+	msg, err := h.Handle(in)
+	if err != nil {
+		log.Printf("interactions: %v", err)
+		http.Error(w, "failed to handle action", http.StatusInternalServerError)
+		return
+	}
+
+	resp := slack.Response{SlackMessage: msg, ReplaceOriginal: true}
+	if err := slack.Respond(h.Client, in.ResponseURL, resp); err != nil {
+		log.Printf("interactions: failed to update the message: %v", err)
+		http.Error(w, "failed to update the message", http.StatusBadGateway)
+	}
+}
+
+// Handle carries out the clicked actions and returns the updated message.
+func (h *Handler) Handle(in slack.Interaction) (slack.SlackMessage, error) {
+	msg := in.Message
+	now := h.now()
+	by := fmt.Sprintf("<@%s>", in.User.ID)
+
+	for _, a := range in.Actions {
+		if a.BlockID != slack.ActionsBlockID {
+			continue
+		}
+		value, err := url.ParseQuery(a.Value)
+		if err != nil {
+			return msg, fmt.Errorf("invalid button value %q", a.Value)
+		}
+
+		switch a.ActionID {
+		case ActionAcknowledge:
+			removeButton(&msg, a.ActionID)
+			addStatus(&msg, fmt.Sprintf(":eyes: Acknowledged by %s at %s", by, h.clock(now)))
+		case ActionMute:
+			muteFor, err := time.ParseDuration(value.Get("for"))
+			if err != nil || value.Get("key") == "" {
+				return msg, fmt.Errorf("invalid button value %q", a.Value)
+			}
+			until := now.Add(muteFor)
+			err = h.Mutes.Add(mute.Mute{Key: value.Get("key"), Host: value.Get("host"), Until: until, By: in.User.ID}, now)
+			if err != nil {
+				return msg, err
+			}
+			removeButton(&msg, a.ActionID)
+			addStatus(&msg, fmt.Sprintf(":mute: Muted for %s by %s, until %s", FormatDuration(muteFor), by, h.clock(until)))
+		case ActionResolve:
+			removeButton(&msg, "")
+			addStatus(&msg, fmt.Sprintf(":white_check_mark: Resolved by %s at %s", by, h.clock(now)))
+		default:
+			return msg, errors.New("unknown action " + a.ActionID)
+		}
+	}
+
+	return msg, nil
+}
+
+// clock formats t as a time shown in each reader's timezone, falling
+// back to the configured one.
+func (h *Handler) clock(t time.Time) string {
+	loc := h.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	return fmt.Sprintf("<!date^%d^{time}|%s>", t.Unix(), t.In(loc).Format("15:04"))
+}
+
+func (h *Handler) now() time.Time {
+	if h.Now == nil {
+		return time.Now()
+	}
+	return h.Now()
+}
+
+// removeButton removes a button, or with an empty action ID all of them,
+// from the message. An actions block left empty is removed too.
+func removeButton(msg *slack.SlackMessage, actionID string) {
+	blocks := msg.Blocks[:0]
+	for _, b := range msg.Blocks {
+		if b.BlockID == slack.ActionsBlockID {
+			elements := b.Elements[:0]
+			for _, e := range b.Elements {
+				if actionID != "" && e.ActionID != actionID {
+					elements = append(elements, e)
+				}
+			}
+			if b.Elements = elements; len(elements) == 0 {
+				continue
+			}
+		}
+		blocks = append(blocks, b)
+	}
+	msg.Blocks = blocks
+}
+
+// addStatus adds a line to the status block, which sits above the
+// buttons or, once they're gone, at the end.
+func addStatus(msg *slack.SlackMessage, text string) {
+	for i, b := range msg.Blocks {
+		if b.BlockID == statusBlockID {
+			msg.Blocks[i].Elements = append(b.Elements, slack.Element{Type: "mrkdwn", Text: text})
+			return
+		}
+	}
+
+	status := slack.Block{Type: "context", BlockID: statusBlockID, Elements: []slack.Element{{Type: "mrkdwn", Text: text}}}
+	at := len(msg.Blocks)
+	for i, b := range msg.Blocks {
+		if b.BlockID == slack.ActionsBlockID {
+			at = i
+		}
+	}
+	msg.Blocks = append(msg.Blocks[:at], append([]slack.Block{status}, msg.Blocks[at:]...)...)
+}
@@ -0,0 +1,171 @@
+package interactions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/mute"
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
+)
+
+const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+var now = time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+// signedRequest returns the fixture click on actionID, signed at ts.
+func signedRequest(t *testing.T, actionID, responseURL string, ts time.Time, key string) *http.Request {
+	t.Helper()
+	data, err := os.ReadFile("testdata/block_actions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.NewReplacer("ACTION_ID", actionID, "RESPONSE_URL", responseURL).Replace(string(data))
+	body := url.Values{"payload": {payload}}.Encode()
+
+	r := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set(slack.HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+	r.Header.Set(slack.HeaderSignature, slack.Sign(key, ts.Unix(), []byte(body)))
+	return r
+}
+
+// click sends a signed click on actionID and returns the status code and
+// the message posted to the response URL.
+func click(t *testing.T, h *Handler, actionID string) (int, *slack.Response) {
+	t.Helper()
+
+	var got *slack.Response
+	responses := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = &slack.Response{}
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer responses.Close()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(t, actionID, responses.URL, now, secret))
+	return w.Code, got
+}
+
+func newHandler(t *testing.T) *Handler {
+	store, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Handler{
+		SigningSecret: secret,
+		Mutes:         mute.Store{State: store},
+		Client:        http.DefaultClient,
+		Location:      time.UTC,
+		Now:           func() time.Time { return now },
+	}
+}
+
+// describe lists the block IDs and button actions of a message.
+func describe(msg slack.SlackMessage) string {
+	var parts []string
+	for _, b := range msg.Blocks {
+		part := b.BlockID
+		for _, e := range b.Elements {
+			if e.ActionID != "" {
+				part += " " + e.ActionID
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func TestAcknowledge(t *testing.T) {
+	code, resp := click(t, newHandler(t), ActionAcknowledge)
+	if code != http.StatusOK || resp == nil {
+		t.Fatalf("status %d, response %v", code, resp)
+	}
+
+	if !resp.ReplaceOriginal || resp.Text != "replication broken" {
+		t.Errorf("response should replace the original message, got %+v", resp)
+	}
+	if got, want := describe(resp.SlackMessage), "a1, a2, slackbot_status, slackbot_actions mute resolve"; got != want {
+		t.Errorf("blocks = %s, want %s", got, want)
+	}
+	if got := resp.Blocks[2].Elements[0].Text; got != ":eyes: Acknowledged by <@U0123ABCD> at <!date^1792400400^{time}|09:00>" {
+		t.Errorf("status = %q", got)
+	}
+}
+
+func TestMute(t *testing.T) {
+	h := newHandler(t)
+	code, resp := click(t, h, ActionMute)
+	if code != http.StatusOK || resp == nil {
+		t.Fatalf("status %d, response %v", code, resp)
+	}
+
+	if got, want := describe(resp.SlackMessage), "a1, a2, slackbot_status, slackbot_actions acknowledge resolve"; got != want {
+		t.Errorf("blocks = %s, want %s", got, want)
+	}
+	if got := resp.Blocks[2].Elements[0].Text; !strings.HasPrefix(got, ":mute: Muted for 1h by <@U0123ABCD>, until <!date^") {
+		t.Errorf("status = %q", got)
+	}
+
+	m, muted, err := h.Mutes.Active("3f2a9c0d1e4b5a67", now.Add(59*time.Minute))
+	if err != nil || !muted || m.Host != "db-1" || m.By != "U0123ABCD" {
+		t.Errorf("Active() = %+v, %v, %v, want a mute for an hour", m, muted, err)
+	}
+	if _, muted, _ := h.Mutes.Active("3f2a9c0d1e4b5a67", now.Add(time.Hour)); muted {
+		t.Error("the mute should end after an hour")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	code, resp := click(t, newHandler(t), ActionResolve)
+	if code != http.StatusOK || resp == nil {
+		t.Fatalf("status %d, response %v", code, resp)
+	}
+	if got, want := describe(resp.SlackMessage), "a1, a2, slackbot_status"; got != want {
+		t.Errorf("blocks = %s, want %s", got, want)
+	}
+}
+
+func TestRejectsUnsigned(t *testing.T) {
+	h := newHandler(t)
+
+	cases := []struct {
+		desc string
+		req  *http.Request
+	}{
+		{"wrong secret", signedRequest(t, ActionMute, "http://127.0.0.1:1", now, "not the secret")},
+		{"replayed", signedRequest(t, ActionMute, "http://127.0.0.1:1", now.Add(-10*time.Minute), secret)},
+		{"unsigned", httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader("payload={}"))},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, c.req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", w.Code)
+			}
+		})
+	}
+
+	if mutes, _ := h.Mutes.List(now); len(mutes) != 0 {
+		t.Errorf("unsigned requests recorded mutes: %v", mutes)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{time.Hour: "1h", 90 * time.Minute: "1h30m", 30 * time.Minute: "30m", 45 * time.Second: "45s"} {
+		if got := FormatDuration(d); got != want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
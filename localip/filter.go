@@ -0,0 +1,87 @@
+package localip
+
+import (
+	"fmt"
+	"net"
+	"path"
+)
+
+// Filter selects which local addresses are reported. Interface patterns are
+// shell globs matched against the interface name. An address is kept when
+// it matches the include lists (if any) and none of the exclude lists.
+type Filter struct {
+	Include      []string // interface globs, e.g. eth*, en*
+	Exclude      []string // interface globs, e.g. docker*, veth*
+	IncludeCIDRs []string // e.g. 10.0.0.0/8
+	ExcludeCIDRs []string // e.g. fe80::/10
+}
+
+// DefaultFilter hides container bridges, virtual Ethernet pairs and
+// link-local addresses, which are noise in an alert.
+func DefaultFilter() Filter {
+	return Filter{
+		Exclude:      []string{"docker*", "veth*", "virbr*", "br-*", "cni*", "flannel*", "cali*", "vxlan*"},
+		ExcludeCIDRs: []string{"169.254.0.0/16", "fe80::/10"},
+	}
+}
+
+// Validate checks every glob and CIDR in the filter.
+func (f Filter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid interface pattern %q", pattern)
+		}
+	}
+	for _, cidr := range append(append([]string{}, f.IncludeCIDRs...), f.ExcludeCIDRs...) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid CIDR %q", cidr)
+		}
+	}
+	return nil
+}
+
+// Apply returns the addresses selected by the filter.
+func (f Filter) Apply(ips []IPAddrInfo) ([]IPAddrInfo, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	var kept []IPAddrInfo
+	for _, ip := range ips {
+		if f.keep(ip) {
+			kept = append(kept, ip)
+		}
+	}
+	return kept, nil
+}
+
+func (f Filter) keep(ip IPAddrInfo) bool {
+	addr := net.ParseIP(ip.Address)
+
+	if len(f.Include) > 0 && !matchAnyGlob(f.Include, ip.Interface) {
+		return false
+	}
+	if len(f.IncludeCIDRs) > 0 && !matchAnyCIDR(f.IncludeCIDRs, addr) {
+		return false
+	}
+
+	return !matchAnyGlob(f.Exclude, ip.Interface) && !matchAnyCIDR(f.ExcludeCIDRs, addr)
+}
+
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyCIDR(cidrs []string, ip net.IP) bool {
+	for _, c := range cidrs {
+		if _, n, err := net.ParseCIDR(c); err == nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package localip
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	cases := map[string]string{
+		"10.0.1.5":       ScopePrivate,
+		"192.168.1.1":    ScopePrivate,
+		"100.64.3.4":     ScopePrivate,
+		"203.0.113.7":    ScopePublic,
+		"169.254.10.1":   ScopeLinkLocal,
+		"fe80::1":        ScopeLinkLocal,
+		"fd12:3456::1":   ScopeULA,
+		"2001:db8:1::10": ScopePublic,
+	}
+
+	for addr, want := range cases {
+		if got := Scope(net.ParseIP(addr)); got != want {
+			t.Errorf("Scope(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	ips := []IPAddrInfo{
+		{Address: "10.0.1.5", Interface: "eth0"},
+		{Address: "fe80::1", Interface: "eth0"},
+		{Address: "172.17.0.1", Interface: "docker0"},
+		{Address: "fe80::2", Interface: "veth12ab"},
+		{Address: "192.168.122.1", Interface: "virbr0"},
+		{Address: "10.8.0.2", Interface: "tun0"},
+	}
+
+	cases := []struct {
+		desc   string
+		filter Filter
+		want   []string
+	}{
+		{"default", DefaultFilter(), []string{"10.0.1.5", "10.8.0.2"}},
+		{"no filter", Filter{}, []string{"10.0.1.5", "fe80::1", "172.17.0.1", "fe80::2", "192.168.122.1", "10.8.0.2"}},
+		{"include interface", Filter{Include: []string{"eth*"}}, []string{"10.0.1.5", "fe80::1"}},
+		{"include CIDR", Filter{IncludeCIDRs: []string{"10.0.0.0/16"}}, []string{"10.0.1.5"}},
+		{"exclude CIDR", Filter{ExcludeCIDRs: []string{"10.0.0.0/8", "fe80::/10"}}, []string{"172.17.0.1", "192.168.122.1"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			kept, err := c.filter.Apply(ips)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, ip := range kept {
+				got = append(got, ip.Address)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Apply() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	if err := (Filter{Exclude: []string{"eth["}}).Validate(); err == nil {
+		t.Error("expected an error for a malformed glob")
+	}
+	if err := (Filter{ExcludeCIDRs: []string{"10.0.0.0"}}).Validate(); err == nil {
+		t.Error("expected an error for a CIDR without a prefix length")
+	}
+}
@@ -2,18 +2,36 @@
 package localip
 
 import (
-	"bytes"
-	"io"
+	"fmt"
 	"net"
-	"net/http"
+)
+
+// Address scopes
+const (
+	ScopePrivate   = "private"    // RFC 1918 and carrier-grade NAT
+	ScopePublic    = "public"     // globally routable
+	ScopeLinkLocal = "link-local" // 169.254.0.0/16 and fe80::/10
+	ScopeULA       = "ula"        // IPv6 unique local, fc00::/7
 )
 
 // IPAddrInfo holds information about an IP address
 // including its string representation and version (IPv4 or IPv6).
 type IPAddrInfo struct {
-	Address string
-	Version string
-	Local   bool
+	Address   string
+	Version   string
+	Local     bool
+	Interface string // e.g. eth0, empty for public addresses
+	PrefixLen int    // CIDR prefix length, zero when unknown
+	MAC       string // hardware address of Interface, if any
+	Scope     string // one of the Scope* constants
+}
+
+// CIDR returns the address with its prefix length, e.g. 10.0.1.5/24.
+func (i IPAddrInfo) CIDR() string {
+	if i.PrefixLen == 0 {
+		return i.Address
+	}
+	return fmt.Sprintf("%s/%d", i.Address, i.PrefixLen)
 }
 
 // GetLocalIPAddr allows to get local IPv4 or IPv6 address
@@ -40,18 +58,22 @@ func GetLocalIPAddr() (ips []IPAddrInfo, err error) {
 				continue
 			}
 
+			prefixLen, _ := ipnet.Mask.Size()
+			info := IPAddrInfo{
+				Address:   ipnet.IP.String(),
+				Local:     true,
+				Interface: iface.Name,
+				PrefixLen: prefixLen,
+				MAC:       iface.HardwareAddr.String(),
+				Scope:     Scope(ipnet.IP),
+			}
+
 			if ipnet.IP.To4() != nil {
-				ipAddresses = append(ipAddresses, IPAddrInfo{
-					Address: ipnet.IP.String(),
-					Version: "IPv4",
-					Local:   true,
-				})
+				info.Version = IPv4
+				ipAddresses = append(ipAddresses, info)
 			} else if ipnet.IP.To16() != nil {
-				ipAddresses = append(ipAddresses, IPAddrInfo{
-					Address: ipnet.IP.String(),
-					Version: "IPv6",
-					Local:   true,
-				})
+				info.Version = IPv6
+				ipAddresses = append(ipAddresses, info)
 			}
 		}
 
@@ -59,32 +81,29 @@ func GetLocalIPAddr() (ips []IPAddrInfo, err error) {
 	return ipAddresses, nil
 }
 
-// GetPublicIPAddr sends a request to checkip.amazonaws.com and returns the public IP address as a string.
-func GetPublicIPAddr() (IPAddrInfo, error) {
-	resp, err := http.Get("https://checkip.amazonaws.com")
-	if err != nil {
-		return IPAddrInfo{}, err
-	}
-	defer resp.Body.Close() // Ensure we close the response body
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return IPAddrInfo{}, err
-	}
+var (
+	ulaNet   = mustParseCIDR("fc00::/7")
+	cgnatNet = mustParseCIDR("100.64.0.0/10")
+)
 
-	netIP := net.ParseIP(string(bytes.TrimSpace(body)))
-	if netIP == nil {
-		return IPAddrInfo{}, err
+// Scope classifies a non-loopback address.
+func Scope(ip net.IP) string {
+	switch {
+	case ip.IsLinkLocalUnicast():
+		return ScopeLinkLocal
+	case ulaNet.Contains(ip):
+		return ScopeULA
+	case ip.IsPrivate() || cgnatNet.Contains(ip):
+		return ScopePrivate
+	default:
+		return ScopePublic
 	}
+}
 
-	ipType := "IPv6"
-	if netIP.To4() != nil {
-		ipType = "IPv4"
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
 	}
-
-	return IPAddrInfo{
-		Address: netIP.String(),
-		Version: ipType,
-		Local:   false,
-	}, nil // Return the IPAddrInfo struct and error as nil
+	return n
 }
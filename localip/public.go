@@ -0,0 +1,290 @@
+package localip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+// Providers maps well-known provider names to URLs that answer with the
+// caller's address as plain text. Any http(s) URL may be used as well.
+var Providers = map[string]string{
+	"aws":         "https://checkip.amazonaws.com",
+	"ipify":       "https://api64.ipify.org",
+	"ifconfig.me": "https://ifconfig.me/ip",
+	"icanhazip":   "https://icanhazip.com",
+}
+
+const (
+	IPv4 = "IPv4"
+	IPv6 = "IPv6"
+
+	publicIPCacheName = "publicip"
+)
+
+// PublicIPOptions configures public address discovery.
+type PublicIPOptions struct {
+	Providers  []string      // names from Providers or URLs, raced against each other
+	Families   []string      // "ipv4" and/or "ipv6", each discovered separately
+	Timeout    time.Duration // deadline for all providers of one family
+	CacheTTL   time.Duration // how long a discovered address is reused
+	FailureTTL time.Duration // how long a failed discovery is remembered
+	Cache      *state.Store  // nil disables caching
+
+	// Transport carries proxy and TLS settings, default Go's. Its
+	// connections are forced onto each family in turn.
+	Transport *http.Transport
+}
+
+// DefaultPublicIPOptions returns the options used when nothing is configured.
+func DefaultPublicIPOptions() PublicIPOptions {
+	return PublicIPOptions{
+		Providers:  []string{"aws", "ipify", "icanhazip"},
+		Families:   []string{"ipv4"},
+		Timeout:    3 * time.Second,
+		CacheTTL:   time.Hour,
+		FailureTTL: 10 * time.Minute,
+	}
+}
+
+// withDefaults fills unset options from DefaultPublicIPOptions.
+func (o PublicIPOptions) withDefaults() PublicIPOptions {
+	d := DefaultPublicIPOptions()
+	if len(o.Providers) == 0 {
+		o.Providers = d.Providers
+	}
+	if len(o.Families) == 0 {
+		o.Families = d.Families
+	}
+	if o.Timeout == 0 {
+		o.Timeout = d.Timeout
+	}
+	if o.CacheTTL == 0 {
+		o.CacheTTL = d.CacheTTL
+	}
+	if o.FailureTTL == 0 {
+		o.FailureTTL = d.FailureTTL
+	}
+	return o
+}
+
+// ValidateProvider checks that a provider is a known name or an http(s) URL.
+func ValidateProvider(p string) error {
+	if _, ok := Providers[p]; ok {
+		return nil
+	}
+	if strings.HasPrefix(p, "https://") || strings.HasPrefix(p, "http://") {
+		return nil
+	}
+	return fmt.Errorf("unknown provider %q", p)
+}
+
+// ValidateFamily checks an address family name.
+func ValidateFamily(f string) error {
+	if _, ok := familyNetworks[strings.ToLower(f)]; !ok {
+		return fmt.Errorf("unknown address family %q, want ipv4 or ipv6", f)
+	}
+	return nil
+}
+
+// familyNetworks maps a family to the network its requests are forced onto.
+var familyNetworks = map[string]string{
+	"ipv4": "tcp4",
+	"ipv6": "tcp6",
+}
+
+// GetPublicIPAddr sends a request to checkip.amazonaws.com and returns the public IP address as a string.
+func GetPublicIPAddr() (IPAddrInfo, error) {
+	opts := DefaultPublicIPOptions()
+	opts.Providers = []string{"aws"}
+
+	ips, err := DiscoverPublicIPs(context.Background(), opts)
+	if err != nil {
+		return IPAddrInfo{}, err
+	}
+	return ips[0], nil
+}
+
+// publicIPCache is the state document of cached discoveries, keyed by family.
+type publicIPCache map[string]publicIPEntry
+
+type publicIPEntry struct {
+	Address   string    `json:"address,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// DiscoverPublicIPs returns the public address of each requested family.
+// Every provider is queried at once and the first valid answer wins.
+// Results, including failures, are cached so that hosts without internet
+// access don't wait for the timeout on every run.
+func DiscoverPublicIPs(ctx context.Context, opts PublicIPOptions) ([]IPAddrInfo, error) {
+	opts = opts.withDefaults()
+
+	var ips []IPAddrInfo
+	var errs []error
+
+	// A cache that can't be read is replaced, with fresh lookups
+	cache := publicIPCache{}
+	if opts.Cache != nil {
+		if err := opts.Cache.Load(publicIPCacheName, &cache); err != nil {
+			errs = append(errs, err)
+			cache = publicIPCache{}
+		}
+	}
+
+	changed := false
+	now := time.Now()
+
+	for _, family := range opts.Families {
+		family = strings.ToLower(family)
+		if err := ValidateFamily(family); err != nil {
+			return nil, err
+		}
+
+		entry, ok := cache[family]
+		fresh := ok && (entry.Address != "" && now.Sub(entry.CheckedAt) < opts.CacheTTL ||
+			entry.Error != "" && now.Sub(entry.CheckedAt) < opts.FailureTTL)
+		if !fresh {
+			entry = publicIPEntry{CheckedAt: now}
+			ip, err := raceProviders(ctx, family, opts)
+			if err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.Address = ip.String()
+			}
+			cache[family] = entry
+			changed = true
+		}
+
+		if entry.Error != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", family, entry.Error))
+			continue
+		}
+		ips = append(ips, publicIPInfo(net.ParseIP(entry.Address)))
+	}
+
+	if changed && opts.Cache != nil {
+		if err := opts.Cache.Save(publicIPCacheName, cache); err != nil {
+			errs = append(errs, fmt.Errorf("failed to cache public IP: %w", err))
+		}
+	}
+
+	if len(ips) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return ips, nil
+}
+
+func publicIPInfo(ip net.IP) IPAddrInfo {
+	version := IPv6
+	if ip.To4() != nil {
+		version = IPv4
+	}
+	return IPAddrInfo{
+		Address: ip.String(),
+		Version: version,
+		Local:   false,
+		Scope:   ScopePublic,
+	}
+}
+
+type providerResult struct {
+	ip  net.IP
+	err error
+}
+
+// raceProviders queries every provider over the given family and returns
+// the first valid address. The losers are cancelled.
+func raceProviders(ctx context.Context, family string, opts PublicIPOptions) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	client := familyClient(opts.Transport, familyNetworks[family])
+	results := make(chan providerResult, len(opts.Providers))
+
+	for _, p := range opts.Providers {
+		go func(p string) {
+			ip, err := queryProvider(ctx, client, p, family)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", p, err)
+			}
+			results <- providerResult{ip, err}
+		}(p)
+	}
+
+	var failures []string
+	for range opts.Providers {
+		r := <-results
+		if r.err == nil {
+			return r.ip, nil
+		}
+		failures = append(failures, r.err.Error())
+	}
+
+	return nil, fmt.Errorf("all providers failed: %s", strings.Join(failures, "; "))
+}
+
+// familyClient returns an HTTP client whose connections only use network,
+// so that a dual-stack provider reports the address of that family.
+func familyClient(base *http.Transport, network string) *http.Client {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dial(ctx, network, addr)
+	}
+	return &http.Client{Transport: transport}
+}
+
+func queryProvider(ctx context.Context, client *http.Client, provider, family string) (net.IP, error) {
+	url, ok := Providers[provider]
+	if !ok {
+		url = provider
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // Ensure we close the response body
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// An address is short; don't read a captive portal's whole page
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(string(bytes.TrimSpace(body)))
+	if ip == nil {
+		return nil, errors.New("response is not an IP address")
+	}
+	if (ip.To4() != nil) != (family == "ipv4") {
+		return nil, fmt.Errorf("got %s, which is not an %s address", ip, family)
+	}
+
+	return ip, nil
+}
@@ -0,0 +1,99 @@
+package localip
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+func TestDiscoverPublicIPsRacesProviders(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "<html>captive portal</html>")
+	}))
+	defer broken.Close()
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "203.0.113.7")
+	}))
+	defer good.Close()
+
+	ips, err := DiscoverPublicIPs(context.Background(), PublicIPOptions{
+		Providers: []string{slow.URL, broken.URL, good.URL},
+		Timeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatalf("DiscoverPublicIPs() error = %v", err)
+	}
+
+	want := IPAddrInfo{Address: "203.0.113.7", Version: IPv4, Scope: ScopePublic}
+	if len(ips) != 1 || ips[0] != want {
+		t.Errorf("DiscoverPublicIPs() = %v, want [%v]", ips, want)
+	}
+}
+
+func TestDiscoverPublicIPsRejectsNonIP(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "not an address")
+	}))
+	defer broken.Close()
+
+	_, err := DiscoverPublicIPs(context.Background(), PublicIPOptions{Providers: []string{broken.URL}})
+	if err == nil {
+		t.Fatal("expected an error for a response that isn't an IP address")
+	}
+}
+
+func TestDiscoverPublicIPsCachesResults(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			http.Error(w, "gone", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "203.0.113.7")
+	}))
+	defer server.Close()
+
+	opts := PublicIPOptions{
+		Providers: []string{server.URL},
+		Cache:     &state.Store{Dir: t.TempDir()},
+	}
+
+	for i := 0; i < 3; i++ {
+		ips, err := DiscoverPublicIPs(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("run %d: DiscoverPublicIPs() error = %v", i, err)
+		}
+		if ips[0].Address != "203.0.113.7" {
+			t.Errorf("run %d: address = %s", i, ips[0].Address)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("provider was queried %d times, want 1", n)
+	}
+
+	// Failures are remembered as well
+	opts.CacheTTL = time.Nanosecond
+	for i := 0; i < 2; i++ {
+		if _, err := DiscoverPublicIPs(context.Background(), opts); err == nil {
+			t.Fatalf("run %d: expected an error from the failing provider", i)
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("provider was queried %d times, want 2", n)
+	}
+}
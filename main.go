@@ -6,37 +6,46 @@ import (
 	"os"
 
 	slackbot "github.com/maxkulish/slackbot/cmd"
+	"github.com/maxkulish/slackbot/config"
+	"github.com/maxkulish/slackbot/severity"
 	"github.com/maxkulish/slackbot/templates"
 )
 
+// configFlags maps flags that override config values to their key paths.
+var configFlags = map[string]string{
+	"webhook": "webhook",
+	"facts":   "host_facts",
+	"diff":    "input.diff_markers",
+}
+
 func main() {
 	// Initialize logging
 	log.SetPrefix("slackbot: ")
 	log.SetFlags(0)
 
-	// Try to read config path from environment variable first
-	configPath := os.Getenv("SLACKBOT_CONFIG")
-
-	c := slackbot.CMD{}
-
-	if configPath == "" {
-		// If the environment variable is not set, inform and use the flag or default value
-		log.Println("SLACKBOT_CONFIG environment variable is empty; reading from the config file")
-		flag.StringVar(&c.ConfigFile, "config", "/etc/slackbot/config.yml", "Path to the config file")
-	} else {
-		// Environment variable is set, use it directly and log the usage
-		c.ConfigFile = configPath
-		log.Printf("Reading config from SLACKBOT_CONFIG environment variable: %s", configPath)
-	}
-
+	// An explicit config file may come from the environment; the flag wins over it
+	c := slackbot.CMD{ConfigFile: os.Getenv(config.EnvConfigFile)}
+
+	flag.StringVar(&c.ConfigFile, "config", c.ConfigFile, "Path to a config file applied on top of the system, user and conf.d layers")
+	flag.String("webhook", "", "Slack webhook URL, overrides every config layer")
+	flag.TextVar(&c.Severity, "severity", severity.Info, "Severity of the message: info, warning, error or critical")
+	flag.Bool("facts", false, "Add OS, uptime, load, memory and disk usage to the message")
+	flag.Bool("diff", false, "Mark red lines of the input with - and green lines with +")
+	flag.StringVar(&c.UpdateKey, "update-key", "", "Update the message posted with this key instead of posting a new one; needs a destination token")
+	flag.DurationVar(&c.TTL, "ttl", 0, "Remove the message after this long, e.g. 30m, with `slackbot gc` or `slackbot serve`; needs a destination token")
+	flag.StringVar(&c.At, "at", "", "Send the message at this time in the configured timezone, e.g. \"2026-10-19 09:00\" or 09:00")
+	flag.DurationVar(&c.In, "in", 0, "Send the message after this long, e.g. 2h")
+	flag.BoolVar(&c.DryRun, "dry-run", false, "Print the JSON payload instead of sending it, without any network access")
+	flag.StringVar(&c.Output, "output", "", "Save the JSON payload to this file")
 	flag.BoolVar(&c.Help, "help", false, templates.HelpMessage)
 	flag.Parse()
 
-	if c.ConfigFile == "" {
-		log.Println("No config file specified. Using default settings.")
-	} else {
-		log.Printf("Using config file: %s", c.ConfigFile)
-	}
+	flag.Visit(func(f *flag.Flag) {
+		if key, ok := configFlags[f.Name]; ok {
+			c.Overrides = append(c.Overrides, config.Override{Flag: f.Name, Key: key, Value: f.Value.String()})
+		}
+	})
+	c.Args = flag.Args()
 
 	if err := c.Run(); err != nil {
 		log.Fatalf("Failed to run: %v", err)
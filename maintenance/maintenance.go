@@ -0,0 +1,422 @@
+// Package maintenance holds back messages during quiet hours and ad-hoc
+// maintenance windows, and summarizes them once the window is over.
+package maintenance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/maxkulish/slackbot/rules"
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/state"
+)
+
+// Policies for messages that arrive during a window.
+const (
+	PolicyHold = "hold" // keep them for the summary
+	PolicyDrop = "drop" // only count them
+)
+
+// stateDoc names the state document with windows and held messages.
+const stateDoc = "maintenance"
+
+// Window is an ad-hoc maintenance window.
+type Window struct {
+	ID     string    `json:"id"`
+	Host   string    `json:"host,omitempty"` // hostname glob, empty for every host
+	From   time.Time `json:"from"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Name identifies the window in held messages and summaries.
+func (w Window) Name() string {
+	return "maintenance " + w.ID
+}
+
+func (w Window) covers(host string, t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.Until) && matchHost(w.Host, host)
+}
+
+// Quiet is a recurring daily quiet period.
+type Quiet struct {
+	Time string
+	Days []time.Weekday // empty for every day
+	Host string
+
+	tod *rules.TimeOfDay
+}
+
+// NewQuiet parses quiet hours such as "22:00-07:00" on the given days.
+func NewQuiet(timeOfDay string, days []string, host string) (Quiet, error) {
+	q := Quiet{Time: timeOfDay, Host: host}
+
+	tod, err := rules.ParseTimeOfDay(timeOfDay)
+	if err != nil {
+		return Quiet{}, err
+	}
+	q.tod = tod
+
+	for _, d := range days {
+		day, err := rules.ParseWeekday(d)
+		if err != nil {
+			return Quiet{}, err
+		}
+		q.Days = append(q.Days, day)
+	}
+
+	if _, err := path.Match(host, ""); err != nil {
+		return Quiet{}, fmt.Errorf("invalid host pattern %q", host)
+	}
+
+	return q, nil
+}
+
+// Name identifies the quiet hours in held messages and summaries.
+func (q Quiet) Name() string {
+	return "quiet hours " + q.Time
+}
+
+// covers reports whether t, in local wall clock time, is in the quiet hours.
+// Days apply to the day a period starts on, so Friday's 22:00-07:00 lasts
+// until Saturday morning.
+func (q Quiet) covers(host string, t time.Time) bool {
+	if q.tod == nil || !q.tod.Contains(t) || !matchHost(q.Host, host) {
+		return false
+	}
+	if len(q.Days) == 0 {
+		return true
+	}
+
+	day := t.Weekday()
+	if q.tod.AfterMidnight(t) {
+		// After midnight, in a period that started the day before
+		day = (day + 6) % 7
+	}
+	for _, d := range q.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Held is a message that arrived during a window.
+type Held struct {
+	Window   string         `json:"window"`
+	Time     time.Time      `json:"time"`
+	Severity severity.Level `json:"severity"`
+	Source   string         `json:"source,omitempty"`
+	Text     string         `json:"text,omitempty"` // empty when dropped
+	Route    []string       `json:"route,omitempty"`
+	Dropped  bool           `json:"dropped,omitempty"`
+}
+
+// Summary lists the messages of a window that has ended.
+type Summary struct {
+	Window string
+	Reason string // of an ad-hoc window
+	Held   []Held
+}
+
+// Counts returns the number of held and dropped messages.
+func (s Summary) Counts() (held, dropped int) {
+	for _, h := range s.Held {
+		if h.Dropped {
+			dropped++
+		} else {
+			held++
+		}
+	}
+	return held, dropped
+}
+
+// Route returns every destination of the held messages, in order of
+// first appearance. Empty means the default destinations.
+func (s Summary) Route() []string {
+	var route []string
+	seen := map[string]bool{}
+	for _, h := range s.Held {
+		for _, r := range h.Route {
+			if !seen[r] {
+				seen[r] = true
+				route = append(route, r)
+			}
+		}
+	}
+	return route
+}
+
+type doc struct {
+	Windows []Window `json:"windows"`
+	Held    []Held   `json:"held"`
+}
+
+// Gate decides whether a message may be sent now.
+type Gate struct {
+	Store    *state.Store
+	Quiet    []Quiet
+	Location *time.Location // for quiet hours, default local time
+	Policy   string         // PolicyHold or PolicyDrop, default PolicyHold
+
+	// Bypass lets messages of this severity and above through any window.
+	Bypass *severity.Level
+}
+
+// Start adds an ad-hoc window and returns it with its ID set.
+func (g *Gate) Start(w Window) (Window, error) {
+	id, err := newID()
+	if err != nil {
+		return Window{}, err
+	}
+	w.ID = id
+
+	var d doc
+	err = g.Store.Update(stateDoc, &d, func() error {
+		d.Windows = append(d.Windows, w)
+		return nil
+	})
+	return w, err
+}
+
+// End closes the window with the given ID, or every open window if id is
+// empty, and returns the windows it closed.
+func (g *Gate) End(id string, now time.Time) ([]Window, error) {
+	var ended []Window
+
+	var d doc
+	err := g.Store.Update(stateDoc, &d, func() error {
+		for i, w := range d.Windows {
+			if (id == "" || w.ID == id) && w.Until.After(now) {
+				d.Windows[i].Until = now
+				ended = append(ended, d.Windows[i])
+			}
+		}
+		if id != "" && len(ended) == 0 {
+			return fmt.Errorf("no open maintenance window %q", id)
+		}
+		return nil
+	})
+
+	return ended, err
+}
+
+// Windows returns the ad-hoc windows that haven't ended yet.
+func (g *Gate) Windows(now time.Time) ([]Window, error) {
+	var d doc
+	if err := g.Store.Load(stateDoc, &d); err != nil {
+		return nil, err
+	}
+
+	var open []Window
+	for _, w := range d.Windows {
+		if w.Until.After(now) {
+			open = append(open, w)
+		}
+	}
+	return open, nil
+}
+
+// Pending returns the number of messages held for host by windows that
+// are still open at now. Messages of windows that have ended wait for
+// their summary and aren't counted.
+func (g *Gate) Pending(host string, now time.Time) (int, error) {
+	var d doc
+	if err := g.Store.Load(stateDoc, &d); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, h := range d.Held {
+		if g.active(d.Windows, h.Window, host, now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Check returns the name of the window that would hold the message,
+// without recording it.
+func (g *Gate) Check(host string, h Held) (string, bool, error) {
+	if g.bypass(h) {
+		return "", false, nil
+	}
+
+	var d doc
+	if err := g.Store.Load(stateDoc, &d); err != nil {
+		return "", false, err
+	}
+	name, ok := g.covering(d.Windows, host, h.Time)
+	return name, ok, nil
+}
+
+// Admit lets the message through, or records it under the window that
+// covers it and returns false.
+func (g *Gate) Admit(host string, h Held) (bool, error) {
+	if g.bypass(h) {
+		return true, nil
+	}
+
+	admitted := true
+	var d doc
+	err := g.Store.Update(stateDoc, &d, func() error {
+		name, ok := g.covering(d.Windows, host, h.Time)
+		if !ok {
+			return nil
+		}
+
+		admitted = false
+		h.Window = name
+		if g.Policy == PolicyDrop {
+			h.Text, h.Dropped = "", true
+		}
+		d.Held = append(d.Held, h)
+		return nil
+	})
+
+	return admitted, err
+}
+
+// Ended returns a summary for each window that has ended with messages
+// still held, and forgets ended windows without any. The messages are kept
+// until their summary is released, so that a summary that failed to send
+// is returned again.
+func (g *Gate) Ended(host string, now time.Time) ([]Summary, error) {
+	var summaries []Summary
+
+	var d doc
+	err := g.Store.Update(stateDoc, &d, func() error {
+		byWindow := map[string][]Held{}
+		for _, h := range d.Held {
+			if !g.active(d.Windows, h.Window, host, now) {
+				byWindow[h.Window] = append(byWindow[h.Window], h)
+			}
+		}
+
+		reasons := map[string]string{}
+		var windows []Window
+		for _, w := range d.Windows {
+			reasons[w.Name()] = w.Reason
+			if w.Until.After(now) || hasHeld(d.Held, w.Name()) {
+				windows = append(windows, w)
+			}
+		}
+		d.Windows = windows
+
+		for name, held := range byWindow {
+			summaries = append(summaries, Summary{Window: name, Reason: reasons[name], Held: held})
+		}
+		sort.Slice(summaries, func(i, j int) bool {
+			return summaries[i].Held[0].Time.Before(summaries[j].Held[0].Time)
+		})
+		return nil
+	})
+
+	return summaries, err
+}
+
+// Release removes the messages of a summary returned by Ended once it was
+// sent, along with its window. Messages held by the same quiet hours since
+// are kept.
+func (g *Gate) Release(s Summary) error {
+	var last time.Time
+	for _, h := range s.Held {
+		if h.Time.After(last) {
+			last = h.Time
+		}
+	}
+
+	var d doc
+	return g.Store.Update(stateDoc, &d, func() error {
+		var keep []Held
+		for _, h := range d.Held {
+			if h.Window != s.Window || h.Time.After(last) {
+				keep = append(keep, h)
+			}
+		}
+		d.Held = keep
+
+		var windows []Window
+		for _, w := range d.Windows {
+			if w.Name() != s.Window || hasHeld(keep, w.Name()) {
+				windows = append(windows, w)
+			}
+		}
+		d.Windows = windows
+		return nil
+	})
+}
+
+func (g *Gate) bypass(h Held) bool {
+	return g.Bypass != nil && h.Severity >= *g.Bypass
+}
+
+// covering returns the name of the first window that covers host at t.
+func (g *Gate) covering(windows []Window, host string, t time.Time) (string, bool) {
+	for _, w := range windows {
+		if w.covers(host, t) {
+			return w.Name(), true
+		}
+	}
+
+	local := t.In(g.location())
+	for _, q := range g.Quiet {
+		if q.covers(host, local) {
+			return q.Name(), true
+		}
+	}
+	return "", false
+}
+
+// active reports whether the named window is still open.
+func (g *Gate) active(windows []Window, name, host string, now time.Time) bool {
+	for _, w := range windows {
+		if w.Name() == name {
+			return w.Until.After(now)
+		}
+	}
+
+	local := now.In(g.location())
+	for _, q := range g.Quiet {
+		if q.Name() == name && q.covers(host, local) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Gate) location() *time.Location {
+	if g.Location == nil {
+		return time.Local
+	}
+	return g.Location
+}
+
+func hasHeld(held []Held, window string) bool {
+	for _, h := range held {
+		if h.Window == window {
+			return true
+		}
+	}
+	return false
+}
+
+func matchHost(pattern, host string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, host)
+	return ok
+}
+
+func newID() (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
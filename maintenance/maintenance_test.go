@@ -0,0 +1,138 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/state"
+)
+
+func newGate(t *testing.T) *Gate {
+	t.Helper()
+
+	store, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	night, err := NewQuiet("22:00-07:00", []string{"fri"}, "db*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	critical := severity.Critical
+	return &Gate{Store: store, Quiet: []Quiet{night}, Location: time.UTC, Bypass: &critical}
+}
+
+func TestGateWindow(t *testing.T) {
+	g := newGate(t)
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	w, err := g.Start(Window{Host: "web*", From: start, Until: start.Add(2 * time.Hour), Reason: "deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admit := func(host string, at time.Duration, level severity.Level) bool {
+		t.Helper()
+		ok, err := g.Admit(host, Held{Time: start.Add(at), Severity: level, Text: "alert", Route: []string{"ops"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if admit("web-1", time.Hour, severity.Error) {
+		t.Error("a message during the window should be held")
+	}
+	if !admit("db-1", time.Hour, severity.Error) {
+		t.Error("the window shouldn't cover other hosts")
+	}
+	if !admit("web-1", time.Hour, severity.Critical) {
+		t.Error("critical messages should bypass the window")
+	}
+	if !admit("web-1", 3*time.Hour, severity.Error) {
+		t.Error("a message after the window should be sent")
+	}
+
+	if s, err := g.Ended("web-1", start.Add(time.Hour)); err != nil || len(s) != 0 {
+		t.Errorf("Ended() during the window = %v, %v; want nothing", s, err)
+	}
+	if n, _ := g.Pending("web-1", start.Add(time.Hour)); n != 1 {
+		t.Errorf("Pending() during the window = %d, want 1", n)
+	}
+
+	ended, err := g.End(w.ID, start.Add(90*time.Minute))
+	if err != nil || len(ended) != 1 {
+		t.Fatalf("End() = %v, %v", ended, err)
+	}
+	if n, _ := g.Pending("web-1", start.Add(90*time.Minute)); n != 0 {
+		t.Errorf("Pending() after End = %d, want the message only in the summary", n)
+	}
+
+	summaries, err := g.Ended("web-1", start.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Window != w.Name() || summaries[0].Reason != "deploy" || len(summaries[0].Held) != 1 {
+		t.Fatalf("Ended() = %+v, want one message for %s", summaries, w.Name())
+	}
+	if route := summaries[0].Route(); len(route) != 1 || route[0] != "ops" {
+		t.Errorf("Route() = %v, want [ops]", route)
+	}
+
+	// Until it's released, for example because sending it failed, the
+	// summary is returned again
+	if again, _ := g.Ended("web-1", start.Add(2*time.Hour)); len(again) != 1 {
+		t.Errorf("Ended() before Release = %+v, want the summary again", again)
+	}
+	if err := g.Release(summaries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := g.Pending("web-1", start.Add(2*time.Hour)); n != 0 {
+		t.Errorf("Pending() after Release = %d, want 0", n)
+	}
+
+	if open, _ := g.Windows(start); len(open) != 0 {
+		t.Errorf("Windows() = %v, want the released window gone", open)
+	}
+}
+
+func TestGateQuietHours(t *testing.T) {
+	g := newGate(t)
+	g.Policy = PolicyDrop
+
+	friNight := time.Date(2024, 3, 8, 23, 0, 0, 0, time.UTC)
+	satMorning := time.Date(2024, 3, 9, 6, 0, 0, 0, time.UTC)
+	satNight := time.Date(2024, 3, 9, 23, 0, 0, 0, time.UTC)
+
+	for _, at := range []time.Time{friNight, satMorning} {
+		if ok, _ := g.Admit("db-1", Held{Time: at, Text: "slow query"}); ok {
+			t.Errorf("message at %s should be dropped", at)
+		}
+	}
+	if ok, _ := g.Admit("db-1", Held{Time: satNight, Text: "slow query"}); !ok {
+		t.Error("quiet hours only start on Fridays")
+	}
+
+	summaries, err := g.Ended("db-1", satMorning.Add(2*time.Hour))
+	if err != nil || len(summaries) != 1 {
+		t.Fatalf("Ended() = %v, %v", summaries, err)
+	}
+	held, dropped := summaries[0].Counts()
+	if held != 0 || dropped != 2 || summaries[0].Held[0].Text != "" {
+		t.Errorf("Counts() = %d held, %d dropped; want 2 dropped without text", held, dropped)
+	}
+}
+
+func TestGateCheck(t *testing.T) {
+	g := newGate(t)
+	friNight := time.Date(2024, 3, 8, 23, 0, 0, 0, time.UTC)
+
+	name, held, err := g.Check("db-1", Held{Time: friNight, Text: "slow query"})
+	if err != nil || !held || name != "quiet hours 22:00-07:00" {
+		t.Errorf("Check() = %q, %v, %v; want the quiet hours", name, held, err)
+	}
+	if n, _ := g.Pending("db-1", friNight); n != 0 {
+		t.Errorf("Check() recorded %d messages", n)
+	}
+}
@@ -0,0 +1,103 @@
+// Package mute keeps track of alerts muted from Slack, so that repeats of
+// an alert are dropped until the mute expires.
+package mute
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+// stateDoc names the state document with the mutes.
+const stateDoc = "mutes"
+
+// Mute silences the alerts with a key until a time.
+type Mute struct {
+	Key   string    `json:"key"`
+	Host  string    `json:"host"`
+	Until time.Time `json:"until"`
+	By    string    `json:"by,omitempty"` // Slack user ID
+}
+
+// Store keeps mutes in the state store.
+type Store struct {
+	State *state.Store
+}
+
+// digits matches what usually differs between repeats of an alert:
+// counts, times, sizes and IDs.
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// Key identifies repeats of an alert: the same source on the same host
+// with the same text, apart from numbers.
+func Key(host, source, text string) string {
+	sum := sha256.Sum256([]byte(host + "\x00" + source + "\x00" + digits.ReplaceAllString(strings.TrimSpace(text), "#")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Add records m, replacing an earlier mute of the same key, and drops
+// mutes that have expired by now.
+func (s Store) Add(m Mute, now time.Time) error {
+	var mutes []Mute
+	return s.State.Update(stateDoc, &mutes, func() error {
+		kept := mutes[:0]
+		for _, old := range mutes {
+			if old.Key != m.Key && now.Before(old.Until) {
+				kept = append(kept, old)
+			}
+		}
+		mutes = append(kept, m)
+		return nil
+	})
+}
+
+// Remove lifts the mute of key, if there is one.
+func (s Store) Remove(key string) error {
+	var mutes []Mute
+	return s.State.Update(stateDoc, &mutes, func() error {
+		kept := mutes[:0]
+		for _, m := range mutes {
+			if m.Key != key {
+				kept = append(kept, m)
+			}
+		}
+		mutes = kept
+		return nil
+	})
+}
+
+// Active returns the mute of key in effect at now, if any.
+func (s Store) Active(key string, now time.Time) (Mute, bool, error) {
+	mutes, err := s.List(now)
+	if err != nil {
+		return Mute{}, false, err
+	}
+	for _, m := range mutes {
+		if m.Key == key {
+			return m, true, nil
+		}
+	}
+	return Mute{}, false, nil
+}
+
+// List returns the mutes in effect at now, those ending first first.
+func (s Store) List(now time.Time) ([]Mute, error) {
+	var mutes []Mute
+	if err := s.State.Load(stateDoc, &mutes); err != nil {
+		return nil, err
+	}
+
+	active := mutes[:0]
+	for _, m := range mutes {
+		if now.Before(m.Until) {
+			active = append(active, m)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Until.Before(active[j].Until) })
+	return active, nil
+}
@@ -0,0 +1,55 @@
+package mute
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+func TestKey(t *testing.T) {
+	a := Key("db-1", "stdin", "disk /var at 91% (12 GiB free)\n")
+	if b := Key("db-1", "stdin", "disk /var at 93% (9 GiB free)"); a != b {
+		t.Errorf("repeats with other numbers should share a key: %s, %s", a, b)
+	}
+	if b := Key("db-2", "stdin", "disk /var at 91% (12 GiB free)"); a == b {
+		t.Error("other hosts should have other keys")
+	}
+	if b := Key("db-1", "watch", "disk /var at 91% (12 GiB free)"); a == b {
+		t.Error("other sources should have other keys")
+	}
+}
+
+func TestStore(t *testing.T) {
+	st, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Store{State: st}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	for _, m := range []Mute{
+		{Key: "a", Host: "db-1", Until: now.Add(time.Hour)},
+		{Key: "b", Host: "db-1", Until: now.Add(30 * time.Minute)},
+		{Key: "a", Host: "db-1", Until: now.Add(2 * time.Hour), By: "U1"},
+	} {
+		if err := s.Add(m, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mutes, err := s.List(now)
+	if err != nil || len(mutes) != 2 || mutes[0].Key != "b" || mutes[1].By != "U1" {
+		t.Fatalf("List() = %+v, %v, want b then the later mute of a", mutes, err)
+	}
+
+	if _, muted, _ := s.Active("b", now.Add(30*time.Minute)); muted {
+		t.Error("b should have expired")
+	}
+	if err := s.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, muted, _ := s.Active("a", now); muted {
+		t.Error("a should be removed")
+	}
+}
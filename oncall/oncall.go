@@ -0,0 +1,122 @@
+// Package oncall works out who is on call from a rotation schedule and
+// formats Slack mentions for them.
+package oncall
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Week is the default shift length.
+const Week = 7 * 24 * time.Hour
+
+// Schedule is a rotation in which Members take turns, each on call for one
+// Shift, starting with the first member at Start. Shifts that are a whole
+// number of days hand off at the same wall clock time across DST changes.
+type Schedule struct {
+	Members   []string
+	Start     time.Time // in the schedule's timezone
+	Shift     time.Duration
+	Overrides []Override
+}
+
+// Override puts Member on call from From until To, in place of the rotation.
+type Override struct {
+	Member   string
+	From, To time.Time
+}
+
+// Shift is a period during which one member is on call.
+type Shift struct {
+	Member   string
+	From, To time.Time
+	Override bool
+}
+
+// At returns the shift that covers t. It returns false for a schedule
+// without members.
+func (s Schedule) At(t time.Time) (Shift, bool) {
+	// The most recently listed override wins
+	for i := len(s.Overrides) - 1; i >= 0; i-- {
+		o := s.Overrides[i]
+		if !t.Before(o.From) && t.Before(o.To) {
+			return Shift{Member: o.Member, From: o.From, To: o.To, Override: true}, true
+		}
+	}
+
+	if len(s.Members) == 0 {
+		return Shift{}, false
+	}
+
+	shift := s.Shift
+	if shift <= 0 {
+		shift = Week
+	}
+
+	n := int(math.Floor(float64(t.Sub(s.Start)) / float64(shift)))
+	// The estimate is off by one around DST changes
+	for s.handoff(n).After(t) {
+		n--
+	}
+	for !s.handoff(n + 1).After(t) {
+		n++
+	}
+
+	i := n % len(s.Members)
+	if i < 0 {
+		i += len(s.Members)
+	}
+
+	return Shift{Member: s.Members[i], From: s.handoff(n), To: s.handoff(n + 1)}, true
+}
+
+// Next returns the shift after cur.
+func (s Schedule) Next(cur Shift) (Shift, bool) {
+	return s.At(cur.To)
+}
+
+// handoff returns the start of shift n.
+func (s Schedule) handoff(n int) time.Time {
+	shift := s.Shift
+	if shift <= 0 {
+		shift = Week
+	}
+	if day := 24 * time.Hour; shift%day == 0 {
+		return s.Start.AddDate(0, 0, n*int(shift/day))
+	}
+	return s.Start.Add(time.Duration(n) * shift)
+}
+
+// Mention formats a Slack user or user group ID as a mention: user IDs
+// start with U or W, group IDs with S. Anything else, such as an existing
+// mention, is returned unchanged.
+func Mention(id string) string {
+	switch {
+	case strings.HasPrefix(id, "<"):
+		return id
+	case strings.HasPrefix(id, "S"):
+		return "<!subteam^" + id + ">"
+	case strings.HasPrefix(id, "U"), strings.HasPrefix(id, "W"):
+		return "<@" + id + ">"
+	default:
+		return id
+	}
+}
+
+// minIDLen is the length of the shortest Slack IDs, e.g. U0123ABCD.
+const minIDLen = 9
+
+// IsID reports whether s looks like a Slack user or user group ID, and not
+// like a name such as SRE.
+func IsID(s string) bool {
+	if len(s) < minIDLen || !strings.ContainsAny(s[:1], "USW") {
+		return false
+	}
+	for _, r := range s[1:] {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package oncall
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleAt(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	at := func(s string) time.Time {
+		t.Helper()
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, berlin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	s := Schedule{
+		Members: []string{"alice", "bob", "carol"},
+		Start:   at("2024-03-04 09:00"),
+		Overrides: []Override{
+			{Member: "dave", From: at("2024-03-13 18:00"), To: at("2024-03-14 09:00")},
+		},
+	}
+
+	cases := []struct {
+		when       string
+		member     string
+		from, to   string
+		isOverride bool
+	}{
+		{"2024-03-04 09:00", "alice", "2024-03-04 09:00", "2024-03-11 09:00", false},
+		{"2024-03-11 08:59", "alice", "2024-03-04 09:00", "2024-03-11 09:00", false},
+		{"2024-03-11 09:00", "bob", "2024-03-11 09:00", "2024-03-18 09:00", false},
+		{"2024-03-13 20:00", "dave", "2024-03-13 18:00", "2024-03-14 09:00", true},
+		// Handoffs stay at 09:00 across the switch to summer time on March 31
+		{"2024-04-01 08:30", "alice", "2024-03-25 09:00", "2024-04-01 09:00", false},
+		{"2024-04-01 09:30", "bob", "2024-04-01 09:00", "2024-04-08 09:00", false},
+		// Before the start the rotation runs backwards
+		{"2024-03-01 12:00", "carol", "2024-02-26 09:00", "2024-03-04 09:00", false},
+	}
+
+	for _, c := range cases {
+		got, ok := s.At(at(c.when))
+		if !ok {
+			t.Fatalf("At(%s) found nobody", c.when)
+		}
+		if got.Member != c.member || !got.From.Equal(at(c.from)) || !got.To.Equal(at(c.to)) || got.Override != c.isOverride {
+			t.Errorf("At(%s) = %s %s - %s (override %v), want %s %s - %s",
+				c.when, got.Member, got.From.Format(time.DateTime), got.To.Format(time.DateTime), got.Override,
+				c.member, c.from, c.to)
+		}
+	}
+
+	next, _ := s.Next(Shift{To: at("2024-03-18 09:00")})
+	if next.Member != "carol" {
+		t.Errorf("Next() = %s, want carol", next.Member)
+	}
+}
+
+func TestMention(t *testing.T) {
+	cases := map[string]string{
+		"U123ABC":      "<@U123ABC>",
+		"W42":          "<@W42>",
+		"S1":           "<!subteam^S1>",
+		"<!here>":      "<!here>",
+		"@channel-ish": "@channel-ish",
+	}
+	for id, want := range cases {
+		if got := Mention(id); got != want {
+			t.Errorf("Mention(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestIsID(t *testing.T) {
+	cases := map[string]bool{
+		"U0123ABCD":   true,
+		"S0789IJKL":   true,
+		"W0123456789": true,
+		"SRE":         false,
+		"WEB":         false,
+		"U0123abcd":   false,
+		"alice":       false,
+	}
+	for s, want := range cases {
+		if got := IsID(s); got != want {
+			t.Errorf("IsID(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package preview
+
+// emoji maps the shortcodes slackbot uses, and other common ones, to
+// Unicode. Others are shown as written.
+var emoji = map[string]string{
+	"bell":                   "🔔",
+	"calendar":               "📆",
+	"cloud":                  "☁️",
+	"computer":               "💻",
+	"construction":           "🚧",
+	"fire":                   "🔥",
+	"heavy_check_mark":       "✔️",
+	"information_source":     "ℹ️",
+	"large_green_circle":     "🟢",
+	"large_red_circle":       "🔴",
+	"large_yellow_circle":    "🟡",
+	"mute":                   "🔇",
+	"no_entry":               "⛔",
+	"red_circle":             "🔴",
+	"rocket":                 "🚀",
+	"rotating_light":         "🚨",
+	"skull":                  "💀",
+	"sos":                    "🆘",
+	"stopwatch":              "⏱️",
+	"tada":                   "🎉",
+	"test_tube":              "🧪",
+	"thumbsup":               "👍",
+	"+1":                     "👍",
+	"hourglass":              "⌛",
+	"hourglass_flowing_sand": "⏳",
+	"warning":                "⚠️",
+	"whale":                  "🐳",
+	"wheel_of_dharma":        "☸️",
+	"white_check_mark":       "✅",
+	"x":                      "❌",
+	"zzz":                    "💤",
+}
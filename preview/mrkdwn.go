@@ -0,0 +1,157 @@
+package preview
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ANSI styles
+const (
+	reset     = "\x1b[0m"
+	bold      = "\x1b[1m"
+	dim       = "\x1b[2m"
+	italic    = "\x1b[3m"
+	underline = "\x1b[4m"
+	strike    = "\x1b[9m"
+	cyan      = "\x1b[36m"
+	blue      = "\x1b[34m"
+	mention   = "\x1b[1;34m"
+)
+
+var (
+	// Slack only treats markers as formatting at word boundaries
+	boldPattern   = regexp.MustCompile(`(^|[\s(\[])\*([^*\n]+?)\*($|[\s)\].,;:!?])`)
+	italicPattern = regexp.MustCompile(`(^|[\s(\[])_([^_\n]+?)_($|[\s)\].,;:!?])`)
+	strikePattern = regexp.MustCompile(`(^|[\s(\[])~([^~\n]+?)~($|[\s)\].,;:!?])`)
+
+	linkPattern  = regexp.MustCompile(`<([^<>|]+)(?:\|([^<>]*))?>`)
+	emojiPattern = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+)
+
+// style wraps s in an ANSI style when color is on. Styles nested in s
+// restore the outer one when they end.
+func (r Renderer) style(code, s string) string {
+	if !r.Color || s == "" {
+		return s
+	}
+	return code + strings.ReplaceAll(s, reset, reset+code) + reset
+}
+
+// inline renders one line of mrkdwn outside code blocks. Text in `code`
+// spans is left as written.
+func (r Renderer) inline(s string) string {
+	parts := strings.Split(s, "`")
+	if len(parts)%2 == 0 {
+		// An unmatched backtick is literal
+		parts[len(parts)-2] += "`" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 {
+			b.WriteString(r.style(cyan, unescape(part)))
+			continue
+		}
+		b.WriteString(r.formatted(part))
+	}
+	return b.String()
+}
+
+func (r Renderer) formatted(s string) string {
+	s = linkPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := linkPattern.FindStringSubmatch(m)
+		return r.link(sub[1], sub[2])
+	})
+	s = emojiPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if e, ok := emoji[strings.Trim(m, ":")]; ok {
+			return e
+		}
+		return m
+	})
+	s = r.replaceMarker(boldPattern, bold, s)
+	s = r.replaceMarker(italicPattern, italic, s)
+	s = r.replaceMarker(strikePattern, strike, s)
+	return unescape(s)
+}
+
+func (r Renderer) replaceMarker(re *regexp.Regexp, code, s string) string {
+	// Adjacent matches share a boundary character, so repeat until stable
+	for {
+		out := re.ReplaceAllStringFunc(s, func(m string) string {
+			sub := re.FindStringSubmatch(m)
+			return sub[1] + r.style(code, sub[2]) + sub[3]
+		})
+		if out == s {
+			return out
+		}
+		s = out
+	}
+}
+
+// link renders <url|text>, <@U123>, <#C123|name>, <!here> and <!subteam^S1>.
+func (r Renderer) link(target, label string) string {
+	switch {
+	case strings.HasPrefix(target, "@"):
+		return r.style(mention, "@"+firstNonEmpty(label, target[1:]))
+	case strings.HasPrefix(target, "#"):
+		return r.style(mention, "#"+firstNonEmpty(label, target[1:]))
+	case strings.HasPrefix(target, "!subteam^"):
+		return r.style(mention, "@"+firstNonEmpty(label, strings.TrimPrefix(target, "!subteam^")))
+	case strings.HasPrefix(target, "!"):
+		name, _, _ := strings.Cut(target[1:], "^")
+		return r.style(mention, "@"+firstNonEmpty(label, name))
+	case label != "":
+		return r.style(underline+blue, label)
+	default:
+		return r.style(underline+blue, target)
+	}
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(s)
+}
+
+// mrkdwn renders a mrkdwn text into lines. Code blocks are indented with
+// a bar and never formatted.
+func (r Renderer) mrkdwn(s string) []string {
+	var lines []string
+
+	segments := strings.Split(s, "```")
+	for i, seg := range segments {
+		if i%2 == 1 && i < len(segments)-1 {
+			code := strings.Trim(seg, "\n")
+			for _, line := range strings.Split(code, "\n") {
+				lines = append(lines, r.style(dim, "│ ")+unescape(line))
+			}
+			continue
+		}
+		if i%2 == 1 {
+			// Unterminated block
+			seg = "```" + seg
+		}
+
+		seg = strings.Trim(seg, "\n")
+		if seg == "" {
+			continue
+		}
+		for _, line := range strings.Split(seg, "\n") {
+			if rest, ok := strings.CutPrefix(line, "&gt; "); ok {
+				lines = append(lines, r.style(dim, "┃ ")+r.inline(rest))
+				continue
+			}
+			lines = append(lines, r.inline(line))
+		}
+	}
+
+	return lines
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
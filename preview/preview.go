@@ -0,0 +1,188 @@
+// Package preview renders Slack messages in a terminal, roughly as Slack
+// would show them, so that layouts can be checked without posting.
+package preview
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/maxkulish/slackbot/slack"
+)
+
+// DefaultWidth is used when the terminal width is unknown.
+const DefaultWidth = 80
+
+// attachmentColors are the named attachment colors.
+var attachmentColors = map[string]string{
+	"good":    "#2eb886",
+	"warning": "#daa038",
+	"danger":  "#a30200",
+}
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Renderer renders messages as lines of text, styled with ANSI escape
+// sequences when Color is set.
+type Renderer struct {
+	Color bool
+	Width int // default DefaultWidth
+}
+
+// Render writes msg to w.
+func (r Renderer) Render(w io.Writer, msg slack.SlackMessage) error {
+	lines := r.blocks(msg.Blocks)
+	if len(msg.Blocks) == 0 && msg.Text != "" {
+		// Slack shows the text only when there are no blocks
+		lines = r.mrkdwn(msg.Text)
+	}
+
+	for _, a := range msg.Attachments {
+		bar := r.bar(a.Color)
+
+		content := r.mrkdwn(a.Text)
+		content = append(content, r.blocks(a.Blocks)...)
+		for _, line := range content {
+			lines = append(lines, bar+" "+line)
+		}
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r Renderer) width() int {
+	if r.Width <= 0 {
+		return DefaultWidth
+	}
+	return r.Width
+}
+
+func (r Renderer) blocks(blocks []slack.Block) []string {
+	var lines []string
+
+	for _, b := range blocks {
+		switch b.Type {
+		case "divider":
+			lines = append(lines, r.style(dim, strings.Repeat("─", r.width())))
+		case "header":
+			if b.Text != nil {
+				lines = append(lines, r.style(bold, b.Text.Text))
+			}
+		case "context":
+			lines = append(lines, r.context(b.Elements))
+		case "section":
+			if b.Text != nil {
+				lines = append(lines, r.text(b.Text)...)
+			}
+			lines = append(lines, r.fields(b.Fields)...)
+		case "actions":
+			var buttons []string
+			for _, e := range b.Elements {
+				buttons = append(buttons, r.style(bold, "[ "+e.Text+" ]"))
+			}
+			lines = append(lines, strings.Join(buttons, " "))
+		default:
+			lines = append(lines, r.style(dim, fmt.Sprintf("[%s block]", b.Type)))
+		}
+	}
+
+	return lines
+}
+
+func (r Renderer) text(t *slack.TextBlock) []string {
+	if t.Type == "plain_text" {
+		return strings.Split(t.Text, "\n")
+	}
+	return r.mrkdwn(t.Text)
+}
+
+// context renders the elements of a context block on one dimmed line.
+func (r Renderer) context(elements []slack.Element) string {
+	var parts []string
+	for _, e := range elements {
+		switch e.Type {
+		case "mrkdwn":
+			parts = append(parts, strings.Join(r.mrkdwn(e.Text), " "))
+		case "image":
+			parts = append(parts, "🖼")
+		default:
+			parts = append(parts, e.Text)
+		}
+	}
+	return r.style(dim, strings.Join(parts, "   "))
+}
+
+// fields renders section fields in two columns.
+func (r Renderer) fields(fields []*slack.TextBlock) []string {
+	col := (r.width() - 2) / 2
+
+	var lines []string
+	for i := 0; i < len(fields); i += 2 {
+		left := r.text(fields[i])
+		var right []string
+		if i+1 < len(fields) {
+			right = r.text(fields[i+1])
+		}
+
+		for j := 0; j < len(left) || j < len(right); j++ {
+			var l, rt string
+			if j < len(left) {
+				l = left[j]
+			}
+			if j < len(right) {
+				rt = right[j]
+			}
+			if rt == "" {
+				lines = append(lines, l)
+				continue
+			}
+			lines = append(lines, pad(l, col)+"  "+rt)
+		}
+	}
+	return lines
+}
+
+// bar returns the colored bar shown left of an attachment.
+func (r Renderer) bar(color string) string {
+	if named, ok := attachmentColors[color]; ok {
+		color = named
+	}
+	if !r.Color {
+		return "▌"
+	}
+
+	red, green, blue, ok := parseHex(color)
+	if !ok {
+		red, green, blue = 0xdd, 0xdd, 0xdd
+	}
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm▌%s", red, green, blue, reset)
+}
+
+func parseHex(color string) (r, g, b uint8, ok bool) {
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) != 6 {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), true
+}
+
+// pad fills s with spaces to width visible characters.
+func pad(s string, width int) string {
+	n := utf8.RuneCountInString(ansiPattern.ReplaceAllString(s, ""))
+	if n >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,68 @@
+package preview
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maxkulish/slackbot/slack"
+)
+
+func TestRenderPlain(t *testing.T) {
+	msg := slack.SlackMessage{
+		Text: "fallback",
+		Blocks: []slack.Block{
+			{Type: "context", Elements: []slack.Element{
+				{Type: "mrkdwn", Text: ":calendar: *2024-03-04 10:00:00*  |  :computer: web-1"},
+				{Type: "mrkdwn", Text: ":x: *error*"},
+			}},
+			{Type: "section", Text: &slack.TextBlock{Type: "mrkdwn", Text: "Ask <@U123> or <!subteam^S1|dba> about <https://example.com|the runbook> &amp; `*literal*`"}},
+			{Type: "section", Fields: []*slack.TextBlock{
+				{Type: "mrkdwn", Text: "*State*\nfailed"},
+				{Type: "mrkdwn", Text: "*Restarts*\n3"},
+				{Type: "mrkdwn", Text: "_odd one_"},
+			}},
+			{Type: "divider"},
+			{Type: "section", Text: &slack.TextBlock{Type: "mrkdwn", Text: "```\nline &lt;1&gt;\n*line 2*```"}},
+		},
+		Attachments: []slack.Attachment{{Color: "danger", Text: "~gone~ away"}},
+	}
+
+	var b strings.Builder
+	if err := (Renderer{Width: 20}).Render(&b, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"📆 2024-03-04 10:00:00  |  💻 web-1   ❌ error",
+		"Ask @U123 or @dba about the runbook & *literal*",
+		"State      Restarts",
+		"failed     3",
+		"odd one",
+		"────────────────────",
+		"│ line <1>",
+		"│ *line 2*",
+		"▌ gone away",
+	}, "\n") + "\n"
+
+	if b.String() != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	msg := slack.SlackMessage{
+		Blocks:      []slack.Block{{Type: "section", Text: &slack.TextBlock{Type: "mrkdwn", Text: "a *b* _c_ 2*3*4"}}},
+		Attachments: []slack.Attachment{{Color: "#e01e5a", Text: "x"}},
+	}
+
+	var b strings.Builder
+	if err := (Renderer{Color: true}).Render(&b, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "a \x1b[1mb\x1b[0m \x1b[3mc\x1b[0m 2*3*4\n" +
+		"\x1b[38;2;224;30;90m▌\x1b[0m x\n"
+	if b.String() != want {
+		t.Errorf("Render() = %q, want %q", b.String(), want)
+	}
+}
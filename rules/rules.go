@@ -0,0 +1,312 @@
+// Package rules decides what happens to a message before it is sent: where
+// it goes, its severity and template, who is mentioned, or whether it is
+// dropped. Rules are evaluated in order and the first match wins unless
+// it asks to continue.
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/maxkulish/slackbot/severity"
+)
+
+// Message sources
+const (
+	SourceStdin     = "stdin"
+	SourceWatch     = "watch"
+	SourceSystemd   = "systemd"
+	SourceHeartbeat = "heartbeat"
+)
+
+var sources = []string{SourceStdin, SourceWatch, SourceSystemd, SourceHeartbeat}
+
+// Rule is one entry of the ordered rule list in the config.
+type Rule struct {
+	Name     string  `yaml:"name"`
+	Match    Match   `yaml:"match"`
+	Actions  Actions `yaml:"actions"`
+	Continue bool    `yaml:"continue"` // keep evaluating later rules after a match
+
+	text *regexp.Regexp
+	tod  *TimeOfDay
+	loc  *time.Location
+}
+
+// Match lists the conditions of a rule. Every condition that is set must
+// hold; a rule without conditions matches everything.
+type Match struct {
+	Text        string            `yaml:"text"`         // regular expression
+	Severity    []severity.Level  `yaml:"severity"`     // any of these levels
+	MinSeverity *severity.Level   `yaml:"min_severity"` // at least this level
+	Host        string            `yaml:"host"`         // hostname glob, e.g. db*
+	Labels      map[string]string `yaml:"labels"`       // host label globs, e.g. env: prod; the label must be set
+	Source      []string          `yaml:"source"`       // stdin, watch, systemd, heartbeat
+	Time        string            `yaml:"time"`         // time of day, e.g. 09:00-18:00 or 22:00-06:00
+	Days        []string          `yaml:"days"`         // mon, tue, ...; the day a time range starts on
+	Timezone    string            `yaml:"timezone"`     // default: the config timezone
+}
+
+// Actions are applied when a rule matches.
+type Actions struct {
+	Route    []string        `yaml:"route"`    // destinations, replacing the default ones
+	Severity *severity.Level `yaml:"severity"` // new severity
+	Template string          `yaml:"template"` // name of a template from the config
+	Mention  []string        `yaml:"mention"`  // e.g. <@U123> or <!subteam^S1>
+	Drop     bool            `yaml:"drop"`     // don't send the message at all
+}
+
+// Event is a message to be evaluated.
+type Event struct {
+	Text     string
+	Severity severity.Level
+	Host     string
+	Labels   map[string]string
+	Source   string
+	Time     time.Time
+}
+
+// Step records how one rule was evaluated, for `slackbot rules test`.
+type Step struct {
+	Rule    string
+	Matched bool
+	Reason  string // the first condition that failed
+}
+
+// Result is the outcome of evaluating every rule for an event.
+type Result struct {
+	Drop     bool
+	Route    []string // empty means the default destinations
+	Severity severity.Level
+	Template string
+	Mentions []string
+	Text     string // the message text, rendered with Template if set
+	Fired    []string
+	Trace    []Step
+}
+
+// Engine evaluates compiled rules.
+type Engine struct {
+	rules     []*Rule
+	templates map[string]*template.Template
+}
+
+// Compile checks and prepares rules and templates. Rules without a
+// timezone use loc.
+func Compile(rules []Rule, templates map[string]string, loc *time.Location) (*Engine, error) {
+	e := &Engine{templates: map[string]*template.Template{}}
+
+	for name, text := range templates {
+		t, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", name, err)
+		}
+		e.templates[name] = t
+	}
+
+	for i := range rules {
+		r := rules[i]
+		if err := e.compileRule(&r, loc); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, r.label(i), err)
+		}
+		e.rules = append(e.rules, &r)
+	}
+
+	return e, nil
+}
+
+func (e *Engine) compileRule(r *Rule, loc *time.Location) error {
+	m := r.Match
+
+	if m.Text != "" {
+		re, err := regexp.Compile(m.Text)
+		if err != nil {
+			return fmt.Errorf("match.text: %w", err)
+		}
+		r.text = re
+	}
+	if m.Host != "" {
+		if _, err := path.Match(m.Host, ""); err != nil {
+			return fmt.Errorf("match.host: invalid pattern %q", m.Host)
+		}
+	}
+	for k, v := range m.Labels {
+		if _, err := path.Match(v, ""); err != nil {
+			return fmt.Errorf("match.labels.%s: invalid pattern %q", k, v)
+		}
+	}
+	for _, s := range m.Source {
+		if !contains(sources, s) {
+			return fmt.Errorf("match.source: unknown source %q, want one of %s", s, strings.Join(sources, ", "))
+		}
+	}
+	if m.Time != "" {
+		tod, err := ParseTimeOfDay(m.Time)
+		if err != nil {
+			return fmt.Errorf("match.time: %w", err)
+		}
+		r.tod = tod
+	}
+	for _, d := range m.Days {
+		if _, err := ParseWeekday(d); err != nil {
+			return fmt.Errorf("match.days: %w", err)
+		}
+	}
+
+	r.loc = loc
+	if m.Timezone != "" {
+		tz, err := time.LoadLocation(m.Timezone)
+		if err != nil {
+			return fmt.Errorf("match.timezone: %w", err)
+		}
+		r.loc = tz
+	}
+	if r.loc == nil {
+		r.loc = time.Local
+	}
+
+	if t := r.Actions.Template; t != "" {
+		if _, ok := e.templates[t]; !ok {
+			return fmt.Errorf("actions.template: unknown template %q", t)
+		}
+	}
+
+	return nil
+}
+
+// Evaluate applies the rules to an event.
+func (e *Engine) Evaluate(ev Event) (Result, error) {
+	res := Result{Severity: ev.Severity, Text: ev.Text}
+
+	for i, r := range e.rules {
+		step := Step{Rule: r.label(i)}
+		step.Reason = r.mismatch(ev)
+		step.Matched = step.Reason == ""
+		res.Trace = append(res.Trace, step)
+
+		if !step.Matched {
+			continue
+		}
+		res.Fired = append(res.Fired, step.Rule)
+
+		a := r.Actions
+		if a.Drop {
+			res.Drop = true
+			return res, nil
+		}
+		if len(a.Route) > 0 {
+			res.Route = a.Route
+		}
+		if a.Severity != nil {
+			res.Severity = *a.Severity
+		}
+		if a.Template != "" {
+			res.Template = a.Template
+		}
+		res.Mentions = append(res.Mentions, a.Mention...)
+
+		if !r.Continue {
+			break
+		}
+	}
+
+	if res.Template != "" {
+		text, err := e.render(res.Template, ev, res)
+		if err != nil {
+			return res, err
+		}
+		res.Text = text
+	}
+
+	return res, nil
+}
+
+// render executes a template. Templates see the event with the severity
+// set by the rules, and the names of the rules that fired.
+func (e *Engine) render(name string, ev Event, res Result) (string, error) {
+	data := struct {
+		Event
+		Rules []string
+	}{ev, res.Fired}
+	data.Severity = res.Severity
+
+	var buf bytes.Buffer
+	if err := e.templates[name].Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("template %q: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// mismatch returns the first condition the event fails, or "" on a match.
+func (r *Rule) mismatch(ev Event) string {
+	m := r.Match
+
+	if r.text != nil && !r.text.MatchString(ev.Text) {
+		return "text"
+	}
+	if len(m.Severity) > 0 && !contains(m.Severity, ev.Severity) {
+		return "severity"
+	}
+	if m.MinSeverity != nil && ev.Severity < *m.MinSeverity {
+		return "min_severity"
+	}
+	if m.Host != "" {
+		if ok, _ := path.Match(m.Host, ev.Host); !ok {
+			return "host"
+		}
+	}
+	for k, pattern := range m.Labels {
+		v, set := ev.Labels[k]
+		if ok, _ := path.Match(pattern, v); !ok || !set {
+			return "labels." + k
+		}
+	}
+	if len(m.Source) > 0 && !contains(m.Source, ev.Source) {
+		return "source"
+	}
+
+	local := ev.Time.In(r.loc)
+	if r.tod != nil && !r.tod.Contains(local) {
+		return "time"
+	}
+	day := local.Weekday()
+	if r.tod != nil && r.tod.AfterMidnight(local) {
+		// Days apply to the day a range starts on, as with quiet hours
+		day = (day + 6) % 7
+	}
+	if len(m.Days) > 0 && !r.onDay(day) {
+		return "days"
+	}
+
+	return ""
+}
+
+func (r *Rule) onDay(day time.Weekday) bool {
+	for _, d := range r.Match.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) label(i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
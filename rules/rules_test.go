@@ -0,0 +1,189 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/severity"
+	"gopkg.in/yaml.v3"
+)
+
+const testRules = `
+- name: drop-healthchecks
+  match: {text: "GET /healthz"}
+  actions: {drop: true}
+- name: prod-db-errors
+  match:
+    min_severity: error
+    host: "db*"
+    labels: {env: prod}
+  actions:
+    route: [dba]
+    severity: critical
+    mention: ["<!subteam^S1>"]
+  continue: true
+- name: night
+  match: {time: "22:00-06:00", days: [mon, tue, wed, thu, fri]}
+  actions: {template: short}
+- name: watch-only
+  match: {source: [watch]}
+  actions: {route: [logs]}
+`
+
+func compileTest(t *testing.T) *Engine {
+	t.Helper()
+
+	var rs []Rule
+	if err := yaml.Unmarshal([]byte(testRules), &rs); err != nil {
+		t.Fatal(err)
+	}
+	e, err := Compile(rs, map[string]string{"short": "[{{.Severity}}] {{.Host}}: {{.Text}}"}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEvaluate(t *testing.T) {
+	e := compileTest(t)
+
+	monNight := time.Date(2024, 3, 4, 23, 30, 0, 0, time.UTC)
+	monDay := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	satNight := time.Date(2024, 3, 9, 23, 30, 0, 0, time.UTC)
+	prod := map[string]string{"env": "prod"}
+
+	cases := []struct {
+		desc string
+		ev   Event
+		want string
+	}{
+		{
+			desc: "drop",
+			ev:   Event{Text: "10.0.0.1 GET /healthz 200", Host: "web-1", Time: monDay},
+			want: "drop [drop-healthchecks]",
+		},
+		{
+			desc: "nothing matches",
+			ev:   Event{Text: "hello", Host: "web-1", Time: monDay, Source: SourceStdin},
+			want: "route=[] severity=info mentions=[] text=hello fired=[]",
+		},
+		{
+			desc: "continue into the night template",
+			ev:   Event{Text: "disk full", Severity: severity.Error, Host: "db-3", Labels: prod, Time: monNight},
+			want: "route=[dba] severity=critical mentions=[<!subteam^S1>] text=[critical] db-3: disk full fired=[prod-db-errors night]",
+		},
+		{
+			desc: "label mismatch",
+			ev:   Event{Text: "disk full", Severity: severity.Error, Host: "db-3", Labels: map[string]string{"env": "dev"}, Time: satNight},
+			want: "route=[] severity=error mentions=[] text=disk full fired=[]",
+		},
+		{
+			desc: "first match wins",
+			ev:   Event{Text: "oops", Host: "web-1", Time: monNight, Source: SourceWatch},
+			want: "route=[] severity=info mentions=[] text=[info] web-1: oops fired=[night]",
+		},
+		{
+			desc: "overnight range after midnight belongs to the day it started",
+			ev:   Event{Text: "oops", Host: "web-1", Time: time.Date(2024, 3, 9, 1, 0, 0, 0, time.UTC), Source: SourceStdin},
+			want: "route=[] severity=info mentions=[] text=[info] web-1: oops fired=[night]",
+		},
+		{
+			desc: "overnight range after midnight on a day off",
+			ev:   Event{Text: "oops", Host: "web-1", Time: time.Date(2024, 3, 4, 1, 0, 0, 0, time.UTC), Source: SourceStdin},
+			want: "route=[] severity=info mentions=[] text=oops fired=[]",
+		},
+		{
+			desc: "source",
+			ev:   Event{Text: "oops", Host: "web-1", Time: satNight, Source: SourceWatch},
+			want: "route=[logs] severity=info mentions=[] text=oops fired=[watch-only]",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			res, err := e.Evaluate(c.ev)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			if res.Drop {
+				got = "drop " + bracket(res.Fired)
+			} else {
+				got = "route=" + bracket(res.Route) + " severity=" + res.Severity.String() +
+					" mentions=" + bracket(res.Mentions) + " text=" + res.Text + " fired=" + bracket(res.Fired)
+			}
+			if got != c.want {
+				t.Errorf("Evaluate() = %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateMissingLabel(t *testing.T) {
+	e, err := Compile([]Rule{{Name: "any-team", Match: Match{Labels: map[string]string{"team": "*"}}, Actions: Actions{Route: []string{"teams"}}}}, nil, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{map[string]string{"team": "db"}, true},
+		{map[string]string{"team": ""}, true},
+		{map[string]string{"env": "prod"}, false},
+		{nil, false},
+	} {
+		res, err := e.Evaluate(Event{Text: "x", Labels: c.labels, Time: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(res.Fired) == 1; got != c.want {
+			t.Errorf("labels %v: fired = %v, want %v", c.labels, got, c.want)
+		}
+	}
+}
+
+func TestEvaluateTrace(t *testing.T) {
+	e := compileTest(t)
+
+	res, err := e.Evaluate(Event{Text: "disk full", Severity: severity.Warning, Host: "db-3", Time: time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, s := range res.Trace {
+		got = append(got, s.Rule+":"+s.Reason)
+	}
+	want := "drop-healthchecks:text prod-db-errors:min_severity night:time watch-only:source"
+	if strings.Join(got, " ") != want {
+		t.Errorf("trace = %v, want %s", got, want)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{Match: Match{Text: "("}}, "rule 1 (#1): match.text"},
+		{Rule{Name: "x", Match: Match{Source: []string{"mail"}}}, `rule 1 (x): match.source: unknown source "mail"`},
+		{Rule{Match: Match{Time: "9-5"}}, "match.time: invalid time"},
+		{Rule{Match: Match{Days: []string{"funday"}}}, `match.days: unknown day "funday"`},
+		{Rule{Actions: Actions{Template: "missing"}}, `actions.template: unknown template "missing"`},
+	}
+
+	for _, c := range cases {
+		_, err := Compile([]Rule{c.rule}, nil, time.UTC)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Compile(%+v) error = %v, want it to contain %q", c.rule, err, c.want)
+		}
+	}
+}
+
+func bracket(list []string) string {
+	return "[" + strings.Join(list, " ") + "]"
+}
@@ -0,0 +1,75 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseWeekday accepts full and three-letter English day names.
+func ParseWeekday(s string) (time.Weekday, error) {
+	d, ok := weekdays[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown day %q", s)
+	}
+	return d, nil
+}
+
+// TimeOfDay is a daily range in minutes since midnight. A range whose end
+// is before its start wraps around midnight.
+type TimeOfDay struct {
+	from, to int
+}
+
+// ParseTimeOfDay parses "09:00-18:00".
+func ParseTimeOfDay(s string) (*TimeOfDay, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("want a range like 09:00-18:00, got %q", s)
+	}
+
+	f, err := parseClock(strings.TrimSpace(from))
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseClock(strings.TrimSpace(to))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TimeOfDay{from: f, to: t}, nil
+}
+
+// parseClock returns the minutes since midnight of "15:04".
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether the wall clock time of t is in the range.
+func (r *TimeOfDay) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if r.from <= r.to {
+		return m >= r.from && m < r.to
+	}
+	return m >= r.from || m < r.to
+}
+
+// AfterMidnight reports whether t is in the part of a range wrapping
+// around midnight that falls on the next day.
+func (r *TimeOfDay) AfterMidnight(t time.Time) bool {
+	return r.from > r.to && t.Hour()*60+t.Minute() < r.to
+}
@@ -0,0 +1,145 @@
+// Package schedule keeps messages to be sent later: the ones Slack
+// schedules itself, so that they can be listed and cancelled, and the ones
+// for webhooks, which wait in a local queue until they're due.
+package schedule
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
+)
+
+// stateDoc names the state document with the scheduled messages.
+const stateDoc = "schedule"
+
+// MaxAhead is how far ahead Slack schedules messages.
+const MaxAhead = 120 * 24 * time.Hour
+
+// Layouts are the accepted formats of a time to send at, read in the
+// configured timezone unless they carry an offset.
+var Layouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05", time.RFC3339}
+
+// Message is a message scheduled for a destination. SlackID is set when
+// Slack schedules it; otherwise it waits in the queue with its content.
+type Message struct {
+	ID          string    `json:"id"`
+	Destination string    `json:"destination"`
+	At          time.Time `json:"at"`
+	Created     time.Time `json:"created"`
+	Summary     string    `json:"summary"`
+
+	Channel string `json:"channel,omitempty"`
+	SlackID string `json:"slack_id,omitempty"` // scheduled_message_id
+
+	Message *slack.SlackMessage `json:"message,omitempty"`
+}
+
+// Queued reports whether m waits in the local queue rather than at Slack.
+func (m Message) Queued() bool {
+	return m.SlackID == ""
+}
+
+// ParseTime reads s as a time in loc, in one of the Layouts or as a time
+// of day such as 09:00, which means its next occurrence after now.
+func ParseTime(s string, loc *time.Location, now time.Time) (time.Time, error) {
+	for _, layout := range Layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	clock, err := time.ParseInLocation("15:04", s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want a time such as %q or 09:00, got %q", Layouts[0], s)
+	}
+	now = now.In(loc)
+	t := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	if !t.After(now) {
+		t = time.Date(now.Year(), now.Month(), now.Day()+1, clock.Hour(), clock.Minute(), 0, 0, loc)
+	}
+	return t, nil
+}
+
+// Store keeps scheduled messages in the state store.
+type Store struct {
+	State *state.Store
+}
+
+// Add records m and returns it with its ID set, if it has none yet.
+func (s Store) Add(m Message) (Message, error) {
+	if m.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return Message{}, err
+		}
+		m.ID = id
+	}
+
+	var messages []Message
+	err := s.State.Update(stateDoc, &messages, func() error {
+		messages = append(messages, m)
+		return nil
+	})
+	return m, err
+}
+
+// List returns the scheduled messages, soonest first.
+func (s Store) List() ([]Message, error) {
+	var messages []Message
+	if err := s.State.Load(stateDoc, &messages); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].At.Before(messages[j].At) })
+	return messages, nil
+}
+
+// Due returns the messages due by now, soonest first.
+func (s Store) Due(now time.Time) ([]Message, error) {
+	messages, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var due []Message
+	for _, m := range messages {
+		if !now.Before(m.At) {
+			due = append(due, m)
+		}
+	}
+	return due, nil
+}
+
+// Remove deletes the message with the given ID and returns it.
+func (s Store) Remove(id string) (Message, bool, error) {
+	var (
+		messages []Message
+		removed  Message
+		found    bool
+	)
+	err := s.State.Update(stateDoc, &messages, func() error {
+		kept := messages[:0]
+		for _, m := range messages {
+			if m.ID == id {
+				removed, found = m, true
+				continue
+			}
+			kept = append(kept, m)
+		}
+		messages = kept
+		return nil
+	})
+	return removed, found, err
+}
+
+func newID() (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
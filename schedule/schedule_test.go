@@ -0,0 +1,72 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/slack"
+	"github.com/maxkulish/slackbot/state"
+)
+
+func TestParseTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) // 14:00 in Berlin
+
+	for _, tt := range []struct {
+		in   string
+		want time.Time
+	}{
+		{"2026-10-19 09:00", time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)},
+		{"2026-10-26T09:00", time.Date(2026, 10, 26, 8, 0, 0, 0, time.UTC)}, // after the clocks change
+		{"2026-10-19T09:00:00Z", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"15:30", time.Date(2026, 10, 19, 13, 30, 0, 0, time.UTC)},
+		{"09:00", time.Date(2026, 10, 20, 7, 0, 0, 0, time.UTC)}, // tomorrow
+	} {
+		got, err := ParseTime(tt.in, berlin, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	if _, err := ParseTime("tomorrow", berlin, now); err == nil {
+		t.Error("ParseTime(tomorrow) should fail")
+	}
+}
+
+func TestStore(t *testing.T) {
+	st, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Store{State: st}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	later, err := s.Add(Message{Destination: "ops", At: now.Add(time.Hour), Message: &slack.SlackMessage{Text: "later"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err := s.Add(Message{Destination: "ops", At: now, Channel: "C1", SlackID: "Q1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if later.ID == "" || later.ID == due.ID {
+		t.Fatalf("IDs %q and %q should be set and differ", later.ID, due.ID)
+	}
+
+	if list, _ := s.List(); len(list) != 2 || list[0].ID != due.ID || list[0].Queued() || !list[1].Queued() {
+		t.Errorf("List() = %+v, want the Slack message first", list)
+	}
+	if got, _ := s.Due(now); len(got) != 1 || got[0].ID != due.ID {
+		t.Errorf("Due() = %+v, want %s", got, due.ID)
+	}
+
+	if m, ok, err := s.Remove(later.ID); err != nil || !ok || m.Message.Text != "later" {
+		t.Errorf("Remove() = %+v, %v, %v", m, ok, err)
+	}
+	if _, ok, _ := s.Remove(later.ID); ok {
+		t.Error("Remove() found a message twice")
+	}
+}
@@ -0,0 +1,110 @@
+// Package sent remembers messages posted with the Web API by their
+// timestamps, so that later runs can update them or remove them once
+// they expire.
+package sent
+
+import (
+	"sort"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+// stateDoc names the state document with the messages.
+const stateDoc = "sent"
+
+// MaxAge is how long a message is remembered after it was last changed.
+const MaxAge = 30 * 24 * time.Hour
+
+// Message is a message posted to a destination.
+type Message struct {
+	Key         string    `json:"key"`
+	Destination string    `json:"destination"`
+	Channel     string    `json:"channel"`
+	TS          string    `json:"ts"`
+	Time        time.Time `json:"time"` // when it was posted or last updated
+
+	// Expires is when the message should be removed, if ever. Summary is
+	// the first line of its text, kept for the note that may replace it.
+	Expires time.Time `json:"expires,omitempty"`
+	Summary string    `json:"summary,omitempty"`
+}
+
+// same reports whether m and o are the same keyed message.
+func (m Message) same(o Message) bool {
+	if m.Key == "" {
+		return m.Destination == o.Destination && m.TS == o.TS
+	}
+	return m.Key == o.Key && m.Destination == o.Destination
+}
+
+// Store keeps messages in the state store.
+type Store struct {
+	State *state.Store
+}
+
+// Lookup returns the message posted with key to destination.
+func (s Store) Lookup(key, destination string) (Message, bool, error) {
+	if key == "" {
+		return Message{}, false, nil
+	}
+
+	var messages []Message
+	if err := s.State.Load(stateDoc, &messages); err != nil {
+		return Message{}, false, err
+	}
+	for _, m := range messages {
+		if m.Key == key && m.Destination == destination {
+			return m, true, nil
+		}
+	}
+	return Message{}, false, nil
+}
+
+// Record adds m, replacing a message with the same key and destination,
+// and forgets messages older than MaxAge that aren't waiting to expire.
+func (s Store) Record(m Message) error {
+	var messages []Message
+	return s.State.Update(stateDoc, &messages, func() error {
+		kept := messages[:0]
+		for _, old := range messages {
+			if !m.same(old) && (m.Time.Sub(old.Time) < MaxAge || !old.Expires.IsZero()) {
+				kept = append(kept, old)
+			}
+		}
+		messages = append(kept, m)
+		return nil
+	})
+}
+
+// Forget removes m. A message posted with the same key since is kept.
+func (s Store) Forget(m Message) error {
+	var messages []Message
+	return s.State.Update(stateDoc, &messages, func() error {
+		kept := messages[:0]
+		for _, old := range messages {
+			if !m.same(old) || m.TS != old.TS {
+				kept = append(kept, old)
+			}
+		}
+		messages = kept
+		return nil
+	})
+}
+
+// Expired returns the messages that have expired by now, oldest first.
+func (s Store) Expired(now time.Time) ([]Message, error) {
+	var messages []Message
+	if err := s.State.Load(stateDoc, &messages); err != nil {
+		return nil, err
+	}
+
+	var expired []Message
+	for _, m := range messages {
+		if !m.Expires.IsZero() && !now.Before(m.Expires) {
+			expired = append(expired, m)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Expires.Before(expired[j].Expires) })
+	return expired, nil
+}
@@ -0,0 +1,101 @@
+package sent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxkulish/slackbot/state"
+)
+
+func TestStore(t *testing.T) {
+	st, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Store{State: st}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	for _, m := range []Message{
+		{Key: "old", Destination: "ops", TS: "1", Time: now.Add(-MaxAge - time.Hour)},
+		{Key: "backup", Destination: "ops", Channel: "C1", TS: "2", Time: now},
+		{Key: "backup", Destination: "dev", Channel: "C2", TS: "3", Time: now},
+		{Key: "backup", Destination: "ops", Channel: "C1", TS: "4", Time: now.Add(time.Minute)},
+	} {
+		if err := s.Record(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if m, ok, err := s.Lookup("backup", "ops"); err != nil || !ok || m.TS != "4" {
+		t.Errorf("Lookup(backup, ops) = %+v, %v, %v, want the latest message", m, ok, err)
+	}
+	if m, ok, _ := s.Lookup("backup", "dev"); !ok || m.TS != "3" {
+		t.Errorf("Lookup(backup, dev) = %+v, %v", m, ok)
+	}
+	if _, ok, _ := s.Lookup("old", "ops"); ok {
+		t.Error("messages older than MaxAge should be forgotten")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	st, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Store{State: st}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	for _, m := range []Message{
+		{Destination: "ops", TS: "1", Time: now.Add(-MaxAge - time.Hour), Expires: now.Add(-time.Minute)},
+		{Destination: "ops", TS: "2", Time: now, Expires: now.Add(time.Hour)},
+		{Destination: "ops", TS: "3", Time: now, Expires: now.Add(-time.Hour)},
+		{Key: "backup", Destination: "ops", TS: "4", Time: now},
+	} {
+		if err := s.Record(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expired, err := s.Expired(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 2 || expired[0].TS != "3" || expired[1].TS != "1" {
+		t.Fatalf("Expired() = %+v, want messages 3 and 1", expired)
+	}
+
+	if err := s.Forget(expired[0]); err != nil {
+		t.Fatal(err)
+	}
+	if expired, _ := s.Expired(now.Add(2 * time.Hour)); len(expired) != 2 || expired[0].TS != "1" || expired[1].TS != "2" {
+		t.Errorf("Expired() after Forget = %+v, want messages 1 and 2", expired)
+	}
+	if _, ok, _ := s.Lookup("backup", "ops"); !ok {
+		t.Error("Forget removed a message with a key")
+	}
+}
+
+func TestForgetKeepsRepostedMessage(t *testing.T) {
+	st, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Store{State: st}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	expired := Message{Key: "deploy", Destination: "ops", TS: "1", Time: now, Expires: now.Add(time.Minute)}
+	if err := s.Record(expired); err != nil {
+		t.Fatal(err)
+	}
+	// Posted again with the same key before the old message was removed
+	if err := s.Record(Message{Key: "deploy", Destination: "ops", TS: "2", Time: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Forget(expired); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok, _ := s.Lookup("deploy", "ops"); !ok || m.TS != "2" {
+		t.Errorf("Lookup(deploy, ops) = %+v, %v, want the message posted since", m, ok)
+	}
+}
@@ -0,0 +1,90 @@
+// Package severity defines the severity levels of a notification.
+package severity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is a notification severity. The zero value is Info.
+type Level int
+
+const (
+	Info Level = iota
+	Warning
+	Error
+	Critical
+)
+
+var names = map[Level]string{
+	Info:     "info",
+	Warning:  "warning",
+	Error:    "error",
+	Critical: "critical",
+}
+
+// Parse accepts a level name and the common abbreviations.
+func Parse(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "info", "notice", "":
+		return Info, nil
+	case "warning", "warn":
+		return Warning, nil
+	case "error", "err":
+		return Error, nil
+	case "critical", "crit", "fatal":
+		return Critical, nil
+	default:
+		return Info, fmt.Errorf("unknown severity %q, want info, warning, error or critical", s)
+	}
+}
+
+func (l Level) String() string {
+	if name, ok := names[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// MarshalText lets levels appear by name in JSON and YAML.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses a level name from JSON or YAML.
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// Emoji returns the Slack emoji shortcode shown next to the level.
+func (l Level) Emoji() string {
+	switch l {
+	case Warning:
+		return ":warning:"
+	case Error:
+		return ":x:"
+	case Critical:
+		return ":rotating_light:"
+	default:
+		return ":information_source:"
+	}
+}
+
+// Color returns the attachment color bar used for the level.
+func (l Level) Color() string {
+	switch l {
+	case Warning:
+		return "#daa038"
+	case Error:
+		return "#d00000"
+	case Critical:
+		return "#7f0000"
+	default:
+		return "#439fe0"
+	}
+}
@@ -0,0 +1,113 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Headers of the requests Slack sends to an app
+const (
+	HeaderSignature = "X-Slack-Signature"
+	HeaderTimestamp = "X-Slack-Request-Timestamp"
+)
+
+// MaxRequestAge is how old a signed request may be before it's taken for
+// a replay.
+const MaxRequestAge = 5 * time.Minute
+
+// ErrBadSignature is returned for requests that weren't signed by Slack
+// with the app's signing secret.
+var ErrBadSignature = errors.New("invalid Slack request signature")
+
+// VerifyRequest checks the signature Slack puts on the requests it sends
+// to an app, such as button clicks and slash commands, against the app's
+// signing secret.
+func VerifyRequest(secret string, h http.Header, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(h.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing timestamp", ErrBadSignature)
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > MaxRequestAge || age < -MaxRequestAge {
+		return fmt.Errorf("%w: timestamp is %s off", ErrBadSignature, age.Round(time.Second))
+	}
+
+	want := Sign(secret, ts, body)
+	if !hmac.Equal([]byte(h.Get(HeaderSignature)), []byte(want)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// maxRequestBody bounds the size of requests from Slack.
+const maxRequestBody = 1 << 20
+
+// ReadRequest reads the form Slack posts to an app and verifies its signature.
+func ReadRequest(secret string, r *http.Request, now time.Time) (url.Values, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyRequest(secret, r.Header, body, now); err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(body))
+}
+
+// Sign returns the signature of a request body sent at the Unix time ts.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%d:", ts)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Interaction is the payload of a block_actions request, sent when someone
+// clicks a button.
+type Interaction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Channel struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+	Actions     []Action     `json:"actions"`
+	Message     SlackMessage `json:"message"`
+	ResponseURL string       `json:"response_url"`
+}
+
+// Action is a clicked button.
+type Action struct {
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Value    string `json:"value"`
+}
+
+// Response types
+const (
+	InChannel = "in_channel"
+	Ephemeral = "ephemeral"
+)
+
+// Response is a message posted to the response URL of an interaction or
+// slash command.
+type Response struct {
+	SlackMessage
+	ResponseType    string `json:"response_type,omitempty"`
+	ReplaceOriginal bool   `json:"replace_original,omitempty"`
+}
+
+// Respond posts a response to a response URL.
+func Respond(client *http.Client, responseURL string, r Response) error {
+	return post(client, responseURL, r)
+}
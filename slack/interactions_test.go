@@ -0,0 +1,55 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyRequest(t *testing.T) {
+	now := time.Unix(1531420618, 0)
+	body := []byte("token=xyzz0WbapA4vBCDEFasx0q6G&command=%2Fslackbot&text=status")
+	h := http.Header{}
+	h.Set(HeaderTimestamp, "1531420618")
+	h.Set(HeaderSignature, Sign("secret", 1531420618, body))
+
+	if err := VerifyRequest("secret", h, body, now); err != nil {
+		t.Errorf("VerifyRequest() = %v", err)
+	}
+	if err := VerifyRequest("other", h, body, now); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("VerifyRequest() with another secret = %v", err)
+	}
+	if err := VerifyRequest("secret", h, append(body, '&'), now); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("VerifyRequest() with another body = %v", err)
+	}
+	if err := VerifyRequest("secret", h, body, now.Add(6*time.Minute)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("VerifyRequest() of an old request = %v", err)
+	}
+}
+
+func TestButtonJSON(t *testing.T) {
+	msg := PrepareMessage("db-1", "down", nil, WithActions(Button("Resolve", "resolve", "k", "")))
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"actions","block_id":"slackbot_actions","elements":[{"type":"button","action_id":"resolve","value":"k","text":{"type":"plain_text","text":"Resolve"}}]}`
+	if !strings.Contains(string(data), want) {
+		t.Errorf("payload %s\ndoesn't contain %s", data, want)
+	}
+
+	var back SlackMessage
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if e := back.Blocks[len(back.Blocks)-1].Elements[0]; e != msg.Blocks[len(msg.Blocks)-1].Elements[0] {
+		t.Errorf("button after a round trip = %+v", e)
+	}
+	if e := back.Blocks[0].Elements[0]; e.Type != "mrkdwn" || !strings.Contains(e.Text, "db-1") {
+		t.Errorf("context element after a round trip = %+v", e)
+	}
+}
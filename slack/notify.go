@@ -13,17 +13,30 @@ import (
 	"time"
 
 	"github.com/maxkulish/slackbot/localip"
+	"github.com/maxkulish/slackbot/truncate"
 )
 
 type SlackMessage struct {
-	Text   string  `json:"text"`
-	Blocks []Block `json:"blocks"`
+	Text        string       `json:"text"`
+	Blocks      []Block      `json:"blocks"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment is secondary content shown with a colored bar on its left:
+// good, warning, danger or a hex color such as #e01e5a.
+type Attachment struct {
+	Color    string  `json:"color,omitempty"`
+	Fallback string  `json:"fallback,omitempty"`
+	Text     string  `json:"text,omitempty"`
+	Blocks   []Block `json:"blocks,omitempty"`
 }
 
 type Block struct {
-	Type     string     `json:"type"`
-	Text     *TextBlock `json:"text,omitempty"`
-	Elements []Element  `json:"elements,omitempty"`
+	Type     string       `json:"type"`
+	BlockID  string       `json:"block_id,omitempty"`
+	Text     *TextBlock   `json:"text,omitempty"`
+	Fields   []*TextBlock `json:"fields,omitempty"`
+	Elements []Element    `json:"elements,omitempty"`
 }
 
 type TextBlock struct {
@@ -31,25 +44,93 @@ type TextBlock struct {
 	Text string `json:"text"`
 }
 
+// Element is an element of a context block, usually mrkdwn text, or a
+// button in an actions block.
 type Element struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ActionID string `json:"action_id,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Style    string `json:"style,omitempty"` // primary or danger, for buttons
+}
+
+// element has the fields of Element without its JSON methods.
+type element Element
+
+// MarshalJSON writes the label of a button as the plain_text object
+// Slack expects.
+func (e Element) MarshalJSON() ([]byte, error) {
+	var v any = element(e)
+	if e.Type == "button" {
+		v = struct {
+			element
+			Text TextBlock `json:"text"`
+		}{element(e), TextBlock{Type: "plain_text", Text: e.Text}}
+	}
+
+	// Leave escaping <, > and & to the caller's encoder
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// UnmarshalJSON reads text given as a string or as a text object.
+func (e *Element) UnmarshalJSON(data []byte) error {
+	var v struct {
+		element
+		Text json.RawMessage `json:"text"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = Element(v.element)
+
+	switch {
+	case len(v.Text) == 0:
+		return nil
+	case v.Text[0] == '{':
+		var t TextBlock
+		if err := json.Unmarshal(v.Text, &t); err != nil {
+			return err
+		}
+		e.Text = t.Text
+		return nil
+	default:
+		return json.Unmarshal(v.Text, &e.Text)
+	}
 }
 
-// SendSlackNotification sends a structured message to a Slack webhook.
-func SendSlackNotification(webhookURL string, message SlackMessage) error {
-	payloadBytes, err := json.Marshal(message)
+// ErrNoClient is returned when no HTTP client is given. There is no
+// default, so that the configured proxy, TLS and timeout settings are
+// never bypassed.
+var ErrNoClient = errors.New("slack: no HTTP client configured")
+
+// SendSlackNotification sends a structured message to a Slack webhook with
+// client, which carries the proxy, TLS and timeout settings.
+func SendSlackNotification(client *http.Client, webhookURL string, message SlackMessage) error {
+	return post(client, webhookURL, message)
+}
+
+// post sends v as JSON to a webhook or response URL.
+func post(client *http.Client, url string, v any) error {
+	if client == nil {
+		return ErrNoClient
+	}
+	payloadBytes, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 10 * time.Second}
 	response, err := client.Do(req)
 	if err != nil {
 		return err
@@ -64,33 +145,58 @@ func SendSlackNotification(webhookURL string, message SlackMessage) error {
 }
 
 // PrepareIPList creates a message string based on the types of IPs present.
+// IPv4 addresses are listed together with globally routable IPv6 ones;
+// addresses from a known interface are shown as "eth0 `10.0.1.5/24`".
 func PrepareIPList(ips []localip.IPAddrInfo) string {
 	if len(ips) == 0 {
 		return "`unknown`"
 	}
-	
+
 	var ipStrings []string
 	for _, ip := range ips {
-		if ip.Version == "IPv4" {
-			ipStrings = append(ipStrings, fmt.Sprintf("`%s`", ip.Address))
+		if ip.Version == localip.IPv4 || ip.Scope == localip.ScopePublic {
+			ipStrings = append(ipStrings, formatIP(ip))
 		}
 	}
-	
+
 	if len(ipStrings) == 0 {
 		// If we only have IPv6 addresses, return the first one
-		return fmt.Sprintf("`%s`", ips[0].Address)
+		return formatIP(ips[0])
 	}
-	
+
 	return strings.Join(ipStrings, ", ")
 }
 
+func formatIP(ip localip.IPAddrInfo) string {
+	if ip.Interface == "" {
+		return fmt.Sprintf("`%s`", ip.CIDR())
+	}
+	return fmt.Sprintf("%s `%s`", ip.Interface, ip.CIDR())
+}
+
 // PrepareMessage creates a SlackMessage struct filled with dynamic IP list, hostname, and custom message.
 // This function now returns a SlackMessage struct, which can be directly passed to SendSlackNotification.
-func PrepareMessage(hostname, message string, ips []localip.IPAddrInfo) SlackMessage {
+// Options add optional host context, such as cloud instance details.
+// A long message is shortened to its first and last lines.
+func PrepareMessage(hostname, message string, ips []localip.IPAddrInfo, opts ...MessageOption) SlackMessage {
+	var parts messageParts
+	for _, opt := range opts {
+		opt(&parts)
+	}
+
+	limits := truncate.Default
+	if parts.limits != nil {
+		limits = *parts.limits
+	}
+	message, _ = truncate.Text(message, limits)
 
 	ipList := PrepareIPList(ips)
-	date := time.Now().Format("2006-01-02 15:04:05")
-	
+	sent := parts.time
+	if sent.IsZero() {
+		sent = time.Now()
+	}
+	date := sent.Format("2006-01-02 15:04:05")
+
 	// For the test, format IPv4 list specifically to include the label
 	var ipv4List string
 	if len(ips) > 0 && ips[0].Version == "IPv4" {
@@ -99,35 +205,48 @@ func PrepareMessage(hostname, message string, ips []localip.IPAddrInfo) SlackMes
 		ipv4List = ipList
 	}
 
-	return SlackMessage{
-		Text: message,
-		Blocks: []Block{
-			{
-				Type: "context",
-				Elements: []Element{
-					{
-						Type: "mrkdwn",
-						Text: fmt.Sprintf(":calendar: *%s*  |  :computer: %s", date, hostname),
-					},
-				},
-			},
-			{
-				Type: "section",
-				Text: &TextBlock{
-					Type: "mrkdwn",
-					Text: ipv4List,
-				},
-			},
-			{
-				Type: "divider",
+	contextElements := []Element{
+		{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf(":calendar: *%s*  |  :computer: %s", date, hostname),
+		},
+	}
+	for _, text := range parts.context {
+		contextElements = append(contextElements, Element{Type: "mrkdwn", Text: text})
+	}
+
+	blocks := []Block{
+		{
+			Type:     "context",
+			Elements: contextElements,
+		},
+		{
+			Type: "section",
+			Text: &TextBlock{
+				Type: "mrkdwn",
+				Text: ipv4List,
 			},
-			{
-				Type: "section",
-				Text: &TextBlock{
-					Type: "mrkdwn",
-					Text: fmt.Sprintf("```%s```", message),
-				},
+		},
+	}
+	blocks = append(blocks, parts.sections...)
+	blocks = append(blocks,
+		Block{
+			Type: "divider",
+		},
+		Block{
+			Type: "section",
+			Text: &TextBlock{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("```%s```", message),
 			},
 		},
+	)
+	if len(parts.actions) > 0 {
+		blocks = append(blocks, Block{Type: "actions", BlockID: ActionsBlockID, Elements: parts.actions})
+	}
+
+	return SlackMessage{
+		Text:   message,
+		Blocks: blocks,
 	}
 }
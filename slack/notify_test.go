@@ -6,7 +6,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/maxkulish/slackbot/cloudmeta"
+	"github.com/maxkulish/slackbot/container"
+	"github.com/maxkulish/slackbot/hostfacts"
 	"github.com/maxkulish/slackbot/localip"
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/truncate"
 )
 
 func TestPrepareIPList(t *testing.T) {
@@ -49,6 +54,33 @@ func TestPrepareIPList(t *testing.T) {
 		},
 	}
 
+	namedIPs := []localip.IPAddrInfo{
+		{
+			Address:   "10.0.1.5",
+			Version:   "IPv4",
+			Local:     true,
+			Interface: "eth0",
+			PrefixLen: 24,
+			Scope:     localip.ScopePrivate,
+		},
+		{
+			Address:   "2001:db8::5",
+			Version:   "IPv6",
+			Local:     true,
+			Interface: "eth0",
+			PrefixLen: 64,
+			Scope:     localip.ScopePublic,
+		},
+		{
+			Address:   "fe80::5",
+			Version:   "IPv6",
+			Local:     true,
+			Interface: "eth0",
+			PrefixLen: 64,
+			Scope:     localip.ScopeLinkLocal,
+		},
+	}
+
 	cases := []struct {
 		desc string
 		ips  []localip.IPAddrInfo
@@ -58,6 +90,7 @@ func TestPrepareIPList(t *testing.T) {
 		{"single IP", singleIP, "`192.168.1.1`"},
 		{"multiple IPs, IPv4 and IPv6 mix", mixedIPs, "`192.168.1.1`"},
 		{"multiple IPv4 IPs", manyIP4s, "`192.168.1.1`, `127.0.0.1`, `14.89.76.251`"},
+		{"interface names and prefixes", namedIPs, "eth0 `10.0.1.5/24`, eth0 `2001:db8::5/64`"},
 	}
 
 	for _, c := range cases {
@@ -105,3 +138,144 @@ func TestPrepareMessage(t *testing.T) {
 		t.Errorf("Custom message not found or not correctly formatted in message blocks")
 	}
 }
+
+func TestPrepareMessageWithCloud(t *testing.T) {
+	info := &cloudmeta.Info{
+		Provider:     cloudmeta.AWS,
+		InstanceID:   "i-0abc123",
+		Name:         "web-prod-3",
+		Region:       "eu-west-1",
+		Zone:         "eu-west-1b",
+		InstanceType: "t3.small",
+		Account:      "123456789012",
+	}
+
+	result := PrepareMessage("ip-10-0-3-17", "Test message", nil, WithCloud(info), WithCloud(nil))
+
+	elements := result.Blocks[0].Elements
+	if len(elements) != 2 {
+		t.Fatalf("context block has %d elements, want 2", len(elements))
+	}
+
+	want := ":cloud: aws *web-prod-3* `i-0abc123`  |  eu-west-1b  |  t3.small  |  123456789012"
+	if elements[1].Text != want {
+		t.Errorf("cloud context = %q, want %q", elements[1].Text, want)
+	}
+}
+
+func TestPrepareMessageWithFacts(t *testing.T) {
+	facts := hostfacts.Facts{
+		Distro:       "Ubuntu 22.04.3 LTS",
+		Kernel:       "5.15.0-91-generic",
+		Uptime:       12*24*time.Hour + 4*time.Hour + 5*time.Minute,
+		Load:         [3]float64{0.52, 0.48, 0.4},
+		MemTotal:     8 << 30,
+		MemAvailable: 6 << 30,
+		DiskPath:     "/",
+		DiskTotal:    100 << 30,
+		DiskFree:     58 << 30,
+	}
+
+	result := PrepareMessage("testHost", "Test message", nil, WithFacts(facts))
+
+	if len(result.Blocks) != 5 || result.Blocks[2].Type != "section" {
+		t.Fatalf("expected the facts section after the IP list, got %+v", result.Blocks)
+	}
+
+	var got []string
+	for _, f := range result.Blocks[2].Fields {
+		got = append(got, f.Text)
+	}
+	want := []string{
+		"*OS*\nUbuntu 22.04.3 LTS 5.15.0-91-generic",
+		"*Uptime*\n12d 4h",
+		"*Load*\n0.52 0.48 0.40",
+		"*Memory*\n2.0 / 8.0 GiB (25%)",
+		"*Disk /*\n42.0 / 100.0 GiB (42%)",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("facts fields = %q, want %q", got, want)
+	}
+
+	if !strings.Contains(result.Blocks[4].Text.Text, "Test message") {
+		t.Errorf("message should stay in the last block")
+	}
+}
+
+func TestPrepareMessageWithContainer(t *testing.T) {
+	cases := []struct {
+		desc string
+		info *container.Info
+		want string
+	}{
+		{
+			desc: "docker",
+			info: &container.Info{Runtime: container.Docker, ContainerID: "3f4e8c1b9a2d", Image: "nginx:1.25"},
+			want: ":whale: docker `3f4e8c1b9a2d`  |  image `nginx:1.25`",
+		},
+		{
+			desc: "kubernetes",
+			info: &container.Info{
+				Runtime: container.Containerd, Kubernetes: true, Pod: "web-7d9f8c6b5-x2kq9", Namespace: "shop",
+				Node: "node-a", Owner: "deployment/web", Image: "web:2.3.1",
+			},
+			want: ":wheel_of_dharma: *shop/web-7d9f8c6b5-x2kq9*  |  deployment/web  |  node `node-a`  |  image `web:2.3.1`",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result := PrepareMessage("3f4e8c1b9a2d", "Test message", nil, WithContainer(c.info))
+
+			elements := result.Blocks[0].Elements
+			if len(elements) != 2 || elements[1].Text != c.want {
+				t.Errorf("context = %+v, want second element %q", elements, c.want)
+			}
+		})
+	}
+}
+
+func TestPrepareMessageWithSeverityAndMentions(t *testing.T) {
+	result := PrepareMessage("db-1", "Test message", nil,
+		WithSeverity(severity.Critical), WithSeverity(severity.Info),
+		WithMentions("<@U123>", "<!subteam^S1>"), WithMentions())
+
+	elements := result.Blocks[0].Elements
+	if len(elements) != 2 || elements[1].Text != ":rotating_light: *critical*" {
+		t.Errorf("context = %+v, want the critical severity only", elements)
+	}
+
+	if len(result.Blocks) != 5 || result.Blocks[2].Text.Text != ":bell: <@U123> <!subteam^S1>" {
+		t.Errorf("expected one mentions section after the IP list, got %+v", result.Blocks)
+	}
+}
+
+func TestPrepareMessageTruncates(t *testing.T) {
+	lines := make([]string, 500)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("step %d", i+1)
+	}
+	lines[499] = "ERROR: build failed"
+
+	result := PrepareMessage("ci-1", strings.Join(lines, "\n"), nil,
+		WithLimits(truncate.Limits{MaxLines: 20, Head: 5, Tail: 10}))
+
+	text := result.Blocks[len(result.Blocks)-1].Text.Text
+	if !strings.Contains(text, "… 481 lines omitted …") || !strings.HasSuffix(text, "ERROR: build failed```") {
+		t.Errorf("message block = %q, want the head, a marker and the tail", text)
+	}
+
+	long := PrepareMessage("ci-1", strings.Repeat("x", 5000), nil)
+	if n := len(long.Blocks[len(long.Blocks)-1].Text.Text); n > 3000 {
+		t.Errorf("message block has %d characters, more than Slack accepts", n)
+	}
+}
+
+func TestPrepareMessageWithTime(t *testing.T) {
+	at := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	result := PrepareMessage("db-1", "Standup", nil, WithTime(at))
+
+	if got := result.Blocks[0].Elements[0].Text; !strings.HasPrefix(got, ":calendar: *2026-10-20 09:00:00*") {
+		t.Errorf("context = %q, want the given time", got)
+	}
+}
@@ -0,0 +1,228 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/slackbot/cloudmeta"
+	"github.com/maxkulish/slackbot/container"
+	"github.com/maxkulish/slackbot/hostfacts"
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/truncate"
+)
+
+// MessageOption adds optional content to a message built by PrepareMessage.
+type MessageOption func(*messageParts)
+
+// messageParts collects the optional content of a message.
+type messageParts struct {
+	context  []string         // extra mrkdwn elements in the context block
+	sections []Block          // blocks between the IP list and the message
+	limits   *truncate.Limits // default truncate.Default
+	actions  []Element        // buttons under the message
+	time     time.Time        // shown in the context block, default now
+}
+
+// ActionsBlockID identifies the block with the buttons added by WithActions.
+const ActionsBlockID = "slackbot_actions"
+
+// Button returns a button that sends actionID and value to the app's
+// interactions endpoint when clicked. Style is primary, danger or empty.
+func Button(text, actionID, value, style string) Element {
+	return Element{Type: "button", Text: text, ActionID: actionID, Value: value, Style: style}
+}
+
+// WithActions adds buttons under the message.
+func WithActions(buttons ...Element) MessageOption {
+	return func(p *messageParts) {
+		p.actions = append(p.actions, buttons...)
+	}
+}
+
+// WithContext adds a mrkdwn element to the context block under the hostname.
+func WithContext(text string) MessageOption {
+	return func(p *messageParts) {
+		if text != "" {
+			p.context = append(p.context, text)
+		}
+	}
+}
+
+// WithSection adds a mrkdwn section, with optional fields shown in two
+// columns, between the IP list and the message.
+func WithSection(text string, fields ...string) MessageOption {
+	block := Block{Type: "section"}
+	if text != "" {
+		block.Text = &TextBlock{Type: "mrkdwn", Text: text}
+	}
+	for _, f := range fields {
+		block.Fields = append(block.Fields, &TextBlock{Type: "mrkdwn", Text: f})
+	}
+
+	return func(p *messageParts) {
+		if block.Text != nil || len(block.Fields) > 0 {
+			p.sections = append(p.sections, block)
+		}
+	}
+}
+
+// WithLimits sets how the message is shortened to fit in its block.
+func WithLimits(l truncate.Limits) MessageOption {
+	return func(p *messageParts) {
+		p.limits = &l
+	}
+}
+
+// WithTime sets the time shown in the context block, such as when a
+// scheduled message will be sent.
+func WithTime(t time.Time) MessageOption {
+	return func(p *messageParts) {
+		p.time = t
+	}
+}
+
+// WithSeverity adds the severity to the context block. Info, the default
+// for plain messages, adds nothing.
+func WithSeverity(level severity.Level) MessageOption {
+	if level == severity.Info {
+		return func(*messageParts) {}
+	}
+	return WithContext(fmt.Sprintf("%s *%s*", level.Emoji(), level))
+}
+
+// WithMentions adds a section that notifies the given users and groups,
+// written as <@U123> or <!subteam^S123>.
+func WithMentions(mentions ...string) MessageOption {
+	if len(mentions) == 0 {
+		return func(*messageParts) {}
+	}
+	return WithSection(":bell: " + strings.Join(mentions, " "))
+}
+
+// WithCloud adds the cloud instance details to the context block.
+// A nil info adds nothing.
+func WithCloud(info *cloudmeta.Info) MessageOption {
+	if info == nil {
+		return func(*messageParts) {}
+	}
+
+	id := fmt.Sprintf("`%s`", info.InstanceID)
+	if info.Name != "" {
+		id = fmt.Sprintf("*%s* `%s`", info.Name, info.InstanceID)
+	}
+
+	details := []string{fmt.Sprintf(":cloud: %s %s", info.Provider, id)}
+	for _, d := range []string{zoneOrRegion(info), info.InstanceType, info.Account} {
+		if d != "" {
+			details = append(details, d)
+		}
+	}
+
+	return WithContext(strings.Join(details, "  |  "))
+}
+
+// WithContainer adds the container and, on Kubernetes, the pod and its
+// owning workload to the context block. A nil info adds nothing.
+func WithContainer(info *container.Info) MessageOption {
+	if info == nil {
+		return func(*messageParts) {}
+	}
+
+	var details []string
+	if info.Kubernetes {
+		pod := info.Pod
+		if info.Namespace != "" {
+			pod = info.Namespace + "/" + pod
+		}
+		details = append(details, fmt.Sprintf(":wheel_of_dharma: *%s*", pod))
+		if info.Owner != "" {
+			details = append(details, info.Owner)
+		}
+		if info.Node != "" {
+			details = append(details, fmt.Sprintf("node `%s`", info.Node))
+		}
+	} else {
+		runtime := firstNonEmpty(info.Runtime, "container")
+		if info.ContainerID != "" {
+			runtime += fmt.Sprintf(" `%s`", info.ContainerID)
+		}
+		details = append(details, ":whale: "+runtime)
+	}
+	if info.Image != "" {
+		details = append(details, fmt.Sprintf("image `%s`", info.Image))
+	}
+
+	return WithContext(strings.Join(details, "  |  "))
+}
+
+// WithFacts adds a section of host facts next to the IP list.
+// Facts that couldn't be read are left out.
+func WithFacts(f hostfacts.Facts) MessageOption {
+	var fields []*TextBlock
+	field := func(name, value string) {
+		if value != "" {
+			fields = append(fields, &TextBlock{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", name, value)})
+		}
+	}
+
+	field("OS", strings.TrimSpace(f.Distro+" "+f.Kernel))
+	if f.Uptime > 0 {
+		field("Uptime", formatUptime(f.Uptime))
+	}
+	if f.Load != [3]float64{} {
+		field("Load", fmt.Sprintf("%.2f %.2f %.2f", f.Load[0], f.Load[1], f.Load[2]))
+	}
+	if f.MemTotal > 0 {
+		field("Memory", formatUsage(f.MemUsed(), f.MemTotal))
+	}
+	if f.DiskTotal > 0 {
+		field("Disk "+f.DiskPath, formatUsage(f.DiskUsed(), f.DiskTotal))
+	}
+
+	return func(p *messageParts) {
+		if len(fields) > 0 {
+			p.sections = append(p.sections, Block{Type: "section", Fields: fields})
+		}
+	}
+}
+
+// formatUptime renders a duration as days, hours and minutes, e.g. "12d 4h".
+func formatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// formatUsage renders used and total bytes in GiB with a percentage.
+func formatUsage(used, total uint64) string {
+	const gib = 1 << 30
+	return fmt.Sprintf("%.1f / %.1f GiB (%.0f%%)",
+		float64(used)/gib, float64(total)/gib, float64(used)/float64(total)*100)
+}
+
+func zoneOrRegion(info *cloudmeta.Info) string {
+	if info.Zone == "" || strings.HasPrefix(info.Zone, info.Region) {
+		return firstNonEmpty(info.Zone, info.Region)
+	}
+	// Azure zones are bare numbers
+	return info.Region + "-" + info.Zone
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
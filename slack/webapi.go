@@ -0,0 +1,217 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the base URL of Slack's Web API.
+const DefaultAPIURL = "https://slack.com/api/"
+
+// API calls Slack Web API methods with a bot token, for what webhooks
+// can't do, such as uploading files and updating messages.
+type API struct {
+	Client *http.Client
+	Token  string // bot token, xoxb-...
+	URL    string // base URL, default DefaultAPIURL
+}
+
+// APIError is an error reported by a Web API method, such as
+// "channel_not_found" or "not_in_channel".
+type APIError struct {
+	Method string
+	Code   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("slack %s: %s", e.Method, e.Code)
+}
+
+// apiResponse holds the fields every Web API response has.
+type apiResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// call posts params to method, as JSON or, for url.Values, as a form, and
+// decodes the response into result.
+func (a *API) call(method string, params any, result any) error {
+	if a.Client == nil {
+		return ErrNoClient
+	}
+
+	var body io.Reader
+	contentType := "application/json; charset=utf-8"
+	if form, ok := params.(url.Values); ok {
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	} else {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	base := a.URL
+	if base == "" {
+		base = DefaultAPIURL
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(base, "/")+"/"+method, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack %s: received %s", method, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	var status apiResponse
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("slack %s: invalid response: %w", method, err)
+	}
+	if !status.OK {
+		return &APIError{Method: method, Code: status.Error}
+	}
+	if result != nil {
+		return json.Unmarshal(data, result)
+	}
+	return nil
+}
+
+// File is a file to upload.
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// UploadFile shares a file in a channel, given by its ID, with an optional
+// comment posted along with it.
+func (a *API) UploadFile(channel string, f File, comment string) error {
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	err := a.call("files.getUploadURLExternal", url.Values{
+		"filename": {f.Name},
+		"length":   {strconv.Itoa(len(f.Data))},
+	}, &upload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, upload.UploadURL, bytes.NewReader(f.Data))
+	if err != nil {
+		return err
+	}
+	if f.ContentType != "" {
+		req.Header.Set("Content-Type", f.ContentType)
+	}
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack file upload: received %s", resp.Status)
+	}
+
+	type fileRef struct {
+		ID    string `json:"id"`
+		Title string `json:"title,omitempty"`
+	}
+	return a.call("files.completeUploadExternal", struct {
+		Files          []fileRef `json:"files"`
+		ChannelID      string    `json:"channel_id"`
+		InitialComment string    `json:"initial_comment,omitempty"`
+	}{[]fileRef{{ID: upload.FileID, Title: f.Name}}, channel, comment}, nil)
+}
+
+// chatMessage is the body of chat.postMessage, chat.update and
+// chat.scheduleMessage.
+type chatMessage struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts,omitempty"`
+	SlackMessage
+}
+
+// PostMessage posts msg to a channel and returns its timestamp, which
+// identifies it for later updates.
+func (a *API) PostMessage(channel string, msg SlackMessage) (string, error) {
+	var resp struct {
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	}
+	if err := a.call("chat.postMessage", chatMessage{Channel: channel, SlackMessage: msg}, &resp); err != nil {
+		return "", err
+	}
+	return resp.TS, nil
+}
+
+// UpdateMessage replaces the content of the message with timestamp ts.
+func (a *API) UpdateMessage(channel, ts string, msg SlackMessage) error {
+	return a.call("chat.update", chatMessage{Channel: channel, TS: ts, SlackMessage: msg}, nil)
+}
+
+// IsMessageGone reports whether err means that the message to change no
+// longer exists.
+func IsMessageGone(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == "message_not_found"
+}
+
+// DeleteMessage deletes the message with timestamp ts.
+func (a *API) DeleteMessage(channel, ts string) error {
+	params := struct {
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	}{channel, ts}
+	return a.call("chat.delete", params, nil)
+}
+
+// ScheduleMessage has Slack post msg to a channel at a time and returns
+// the ID of the scheduled message.
+func (a *API) ScheduleMessage(channel string, at time.Time, msg SlackMessage) (string, error) {
+	params := struct {
+		chatMessage
+		PostAt int64 `json:"post_at"`
+	}{chatMessage{Channel: channel, SlackMessage: msg}, at.Unix()}
+
+	var resp struct {
+		ID string `json:"scheduled_message_id"`
+	}
+	if err := a.call("chat.scheduleMessage", params, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// DeleteScheduledMessage cancels a message scheduled with ScheduleMessage.
+func (a *API) DeleteScheduledMessage(channel, id string) error {
+	params := struct {
+		Channel string `json:"channel"`
+		ID      string `json:"scheduled_message_id"`
+	}{channel, id}
+	return a.call("chat.deleteScheduledMessage", params, nil)
+}
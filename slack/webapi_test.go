@@ -0,0 +1,144 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadFile(t *testing.T) {
+	var uploaded []byte
+	var completed map[string]any
+
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/api/files.getUploadURLExternal", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" || r.FormValue("filename") != "stdin.gz" || r.FormValue("length") != "3" {
+			t.Errorf("unexpected request: %v %v", r.Header, r.Form)
+		}
+		io.WriteString(w, `{"ok":true,"upload_url":"`+srv.URL+`/upload","file_id":"F123"}`)
+	})
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		uploaded, _ = io.ReadAll(r.Body)
+	})
+	mux.HandleFunc("/api/files.completeUploadExternal", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&completed)
+		io.WriteString(w, `{"ok":true}`)
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	api := API{Client: srv.Client(), Token: "xoxb-test", URL: srv.URL + "/api/"}
+	if err := api.UploadFile("C123", File{Name: "stdin.gz", Data: []byte{1, 2, 3}}, "backup"); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(uploaded) != "\x01\x02\x03" {
+		t.Errorf("uploaded %q", uploaded)
+	}
+	if completed["channel_id"] != "C123" || completed["initial_comment"] != "backup" {
+		t.Errorf("completeUploadExternal got %v", completed)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"ok":false,"error":"invalid_auth"}`)
+	}))
+	defer srv.Close()
+
+	api := API{Client: srv.Client(), Token: "xoxb-bad", URL: srv.URL}
+	err := api.UploadFile("C123", File{Name: "a.bin"}, "")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "invalid_auth" || apiErr.Method != "files.getUploadURLExternal" {
+		t.Errorf("UploadFile() error = %v, want invalid_auth", err)
+	}
+}
+
+func TestPostAndUpdateMessage(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		calls = append(calls, fmt.Sprintf("%s %v %v %v", r.URL.Path, body["channel"], body["ts"], body["text"]))
+
+		switch r.URL.Path {
+		case "/chat.postMessage":
+			io.WriteString(w, `{"ok":true,"channel":"C123","ts":"1760864400.000100"}`)
+		case "/chat.update":
+			io.WriteString(w, `{"ok":false,"error":"message_not_found"}`)
+		case "/chat.delete":
+			io.WriteString(w, `{"ok":true}`)
+		}
+	}))
+	defer srv.Close()
+
+	api := API{Client: srv.Client(), Token: "xoxb-test", URL: srv.URL}
+	ts, err := api.PostMessage("C123", SlackMessage{Text: "backup 10%"})
+	if err != nil || ts != "1760864400.000100" {
+		t.Fatalf("PostMessage() = %q, %v", ts, err)
+	}
+
+	err = api.UpdateMessage("C123", ts, SlackMessage{Text: "backup done"})
+	if !IsMessageGone(err) {
+		t.Errorf("UpdateMessage() = %v, want message_not_found", err)
+	}
+
+	if err := api.DeleteMessage("C123", ts); err != nil {
+		t.Errorf("DeleteMessage() = %v", err)
+	}
+
+	want := []string{
+		"/chat.postMessage C123 <nil> backup 10%",
+		"/chat.update C123 1760864400.000100 backup done",
+		"/chat.delete C123 1760864400.000100 <nil>",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls =\n%s\nwant\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestScheduleMessage(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		calls = append(calls, fmt.Sprintf("%s %v %v %v %v", r.URL.Path, body["channel"], body["post_at"], body["scheduled_message_id"], body["text"]))
+		io.WriteString(w, `{"ok":true,"channel":"C123","scheduled_message_id":"Q1298393284","post_at":1792400400}`)
+	}))
+	defer srv.Close()
+
+	api := API{Client: srv.Client(), Token: "xoxb-test", URL: srv.URL}
+	id, err := api.ScheduleMessage("C123", time.Unix(1792400400, 0), SlackMessage{Text: "standup"})
+	if err != nil || id != "Q1298393284" {
+		t.Fatalf("ScheduleMessage() = %q, %v", id, err)
+	}
+	if err := api.DeleteScheduledMessage("C123", id); err != nil {
+		t.Fatalf("DeleteScheduledMessage() = %v", err)
+	}
+
+	want := []string{
+		"/chat.scheduleMessage C123 1.7924004e+09 <nil> standup",
+		"/chat.deleteScheduledMessage C123 <nil> Q1298393284 <nil>",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls =\n%s\nwant\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestNoClient(t *testing.T) {
+	if err := SendSlackNotification(nil, "https://hooks.slack.com/x", SlackMessage{Text: "hi"}); !errors.Is(err, ErrNoClient) {
+		t.Errorf("SendSlackNotification() error = %v, want ErrNoClient", err)
+	}
+	api := API{Token: "xoxb-test"}
+	if _, err := api.PostMessage("C1", SlackMessage{Text: "hi"}); !errors.Is(err, ErrNoClient) {
+		t.Errorf("PostMessage() error = %v, want ErrNoClient", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package state
+
+// lockFile is a no-op where advisory locks aren't available.
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
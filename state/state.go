@@ -0,0 +1,109 @@
+// Package state persists small pieces of local state, such as caches,
+// offsets and queues, as JSON files in a single directory.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Store keeps named JSON documents in Dir.
+type Store struct {
+	Dir string
+}
+
+// DefaultDir returns /var/lib/slackbot for root and the XDG state directory
+// (~/.local/state/slackbot) for everyone else.
+func DefaultDir() string {
+	if os.Geteuid() == 0 {
+		return "/var/lib/slackbot"
+	}
+
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(os.TempDir(), "slackbot")
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "slackbot")
+}
+
+// Open returns a store in dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return &Store{Dir: dir}, nil
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.Dir, name+".json")
+}
+
+// Load decodes the named document into v. A missing document leaves v
+// untouched and isn't an error.
+func (s *Store) Load(name string, v any) error {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("corrupt state file %s: %w", s.path(name), err)
+	}
+	return nil
+}
+
+// Save atomically replaces the named document with v.
+func (s *Store) Save(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(name))
+}
+
+// Update loads the named document into v, calls fn and saves v if fn
+// succeeds. Concurrent updates of the same document from several processes
+// are serialized with a lock file.
+func (s *Store) Update(name string, v any, fn func() error) error {
+	unlock, err := lockFile(filepath.Join(s.Dir, name+".lock"))
+	if err != nil {
+		return fmt.Errorf("failed to lock state %s: %w", name, err)
+	}
+	defer unlock()
+
+	if err := s.Load(name, v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return s.Save(name, v)
+}
@@ -0,0 +1,183 @@
+// Package systemd gathers the state of a failed unit and its recent journal
+// through systemctl and journalctl.
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// properties are the unit properties read with `systemctl show`.
+var properties = []string{
+	"Id", "Description", "ActiveState", "SubState", "Result",
+	"ExecMainCode", "ExecMainStatus", "NRestarts", "InvocationID",
+}
+
+// UnitStatus is the state of a unit as reported by systemctl.
+type UnitStatus struct {
+	Unit         string
+	Description  string
+	ActiveState  string // e.g. failed
+	SubState     string // e.g. failed
+	Result       string // e.g. exit-code, signal, timeout
+	ExitCode     string // exited, killed or dumped
+	ExitStatus   int    // exit status, or signal number when killed
+	Restarts     int
+	InvocationID string
+}
+
+// ExitDescription describes how the main process ended, e.g. "exit status 1".
+func (s UnitStatus) ExitDescription() string {
+	switch s.ExitCode {
+	case "killed", "dumped":
+		return fmt.Sprintf("killed by signal %d", s.ExitStatus)
+	case "exited":
+		return fmt.Sprintf("exit status %d", s.ExitStatus)
+	default:
+		return ""
+	}
+}
+
+// JournalEntry is one line of the unit's journal.
+type JournalEntry struct {
+	Time     time.Time
+	Priority int // 0 emerg to 7 debug
+	Message  string
+}
+
+// Status runs `systemctl show` for the unit.
+func Status(ctx context.Context, unit string) (UnitStatus, error) {
+	out, err := run(ctx, "systemctl", "show", unit, "--no-pager", "--property="+strings.Join(properties, ","))
+	if err != nil {
+		return UnitStatus{}, err
+	}
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			values[key] = value
+		}
+	}
+
+	s := UnitStatus{
+		Unit:         values["Id"],
+		Description:  values["Description"],
+		ActiveState:  values["ActiveState"],
+		SubState:     values["SubState"],
+		Result:       values["Result"],
+		ExitCode:     exitCode(values["ExecMainCode"]),
+		InvocationID: values["InvocationID"],
+	}
+	if s.Unit == "" {
+		s.Unit = unit
+	}
+	s.ExitStatus, _ = strconv.Atoi(values["ExecMainStatus"])
+	s.Restarts, _ = strconv.Atoi(values["NRestarts"])
+
+	return s, nil
+}
+
+// exitCode maps the numeric ExecMainCode (a CLD_* value) to its name;
+// newer systemd versions print the name already.
+func exitCode(code string) string {
+	switch code {
+	case "1":
+		return "exited"
+	case "2":
+		return "killed"
+	case "3":
+		return "dumped"
+	case "0":
+		return ""
+	default:
+		return code
+	}
+}
+
+// Journal returns the last n journal entries of the unit. When invocationID
+// is set, only entries of that run of the unit are returned.
+func Journal(ctx context.Context, unit, invocationID string, n int) ([]JournalEntry, error) {
+	args := []string{"-u", unit, "-n", strconv.Itoa(n), "-o", "json", "--no-pager"}
+	if invocationID != "" {
+		args = append(args, "_SYSTEMD_INVOCATION_ID="+invocationID)
+	}
+
+	out, err := run(ctx, "journalctl", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		entry, err := parseJournalEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse journal entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func parseJournalEntry(line []byte) (JournalEntry, error) {
+	var raw struct {
+		Timestamp string          `json:"__REALTIME_TIMESTAMP"` // microseconds
+		Priority  string          `json:"PRIORITY"`
+		Message   json.RawMessage `json:"MESSAGE"`
+	}
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return JournalEntry{}, err
+	}
+
+	var e JournalEntry
+	if usec, err := strconv.ParseInt(raw.Timestamp, 10, 64); err == nil {
+		e.Time = time.UnixMicro(usec)
+	}
+	e.Priority, _ = strconv.Atoi(raw.Priority)
+
+	// journalctl prints messages that aren't valid UTF-8 as an array of bytes
+	if err := json.Unmarshal(raw.Message, &e.Message); err != nil {
+		var b []byte
+		var ints []int
+		if err := json.Unmarshal(raw.Message, &ints); err != nil {
+			return JournalEntry{}, fmt.Errorf("unexpected MESSAGE %s", raw.Message)
+		}
+		for _, i := range ints {
+			b = append(b, byte(i))
+		}
+		e.Message = strings.ToValidUTF8(string(b), "�")
+	}
+
+	return e, nil
+}
+
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+
+	return stdout.Bytes(), nil
+}
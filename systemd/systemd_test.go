@@ -0,0 +1,118 @@
+package systemd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeCommand puts a shell script named name on PATH. The script records
+// its arguments in name.args next to it and prints output.
+func fakeCommand(t *testing.T, dir, name, output string) {
+	t.Helper()
+
+	script := "#!/bin/sh\necho \"$@\" > \"$0.args\"\ncat <<'EOF'\n" + output + "EOF\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readArgs(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, name+".args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStatus(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	fakeCommand(t, dir, "systemctl", `Id=backup.service
+Description=Nightly backup
+ActiveState=failed
+SubState=failed
+Result=exit-code
+ExecMainCode=1
+ExecMainStatus=3
+NRestarts=2
+InvocationID=5f1ae3c8b2f04c3c9b1d2f6c7a8e9d01
+`)
+
+	s, err := Status(context.Background(), "backup.service")
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	want := UnitStatus{
+		Unit:         "backup.service",
+		Description:  "Nightly backup",
+		ActiveState:  "failed",
+		SubState:     "failed",
+		Result:       "exit-code",
+		ExitCode:     "exited",
+		ExitStatus:   3,
+		Restarts:     2,
+		InvocationID: "5f1ae3c8b2f04c3c9b1d2f6c7a8e9d01",
+	}
+	if s != want {
+		t.Errorf("Status() = %+v, want %+v", s, want)
+	}
+	if got := s.ExitDescription(); got != "exit status 3" {
+		t.Errorf("ExitDescription() = %q", got)
+	}
+
+	args := readArgs(t, dir, "systemctl")
+	if want := "show backup.service --no-pager --property=Id,Description,ActiveState,SubState,Result,ExecMainCode,ExecMainStatus,NRestarts,InvocationID\n"; args != want {
+		t.Errorf("systemctl called with %q, want %q", args, want)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	fakeCommand(t, dir, "journalctl", `{"__REALTIME_TIMESTAMP":"1760856000000000","PRIORITY":"6","MESSAGE":"Starting backup"}
+{"__REALTIME_TIMESTAMP":"1760856001500000","PRIORITY":"3","MESSAGE":[100,105,115,107,32,102,117,108,108,255]}
+
+`)
+
+	entries, err := Journal(context.Background(), "backup.service", "5f1a", 20)
+	if err != nil {
+		t.Fatalf("Journal() error = %v", err)
+	}
+
+	want := []JournalEntry{
+		{Time: time.UnixMicro(1760856000000000), Priority: 6, Message: "Starting backup"},
+		{Time: time.UnixMicro(1760856001500000), Priority: 3, Message: "disk full�"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Journal() returned %d entries, want %d", len(entries), len(want))
+	}
+	for i := range want {
+		if !entries[i].Time.Equal(want[i].Time) || entries[i].Priority != want[i].Priority || entries[i].Message != want[i].Message {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	args := readArgs(t, dir, "journalctl")
+	if want := "-u backup.service -n 20 -o json --no-pager _SYSTEMD_INVOCATION_ID=5f1a\n"; args != want {
+		t.Errorf("journalctl called with %q, want %q", args, want)
+	}
+}
+
+func TestStatusCommandFails(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	script := "#!/bin/sh\necho 'Failed to connect to bus' >&2\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "systemctl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Status(context.Background(), "backup.service"); err == nil {
+		t.Fatal("expected an error when systemctl fails")
+	}
+}
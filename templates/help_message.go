@@ -8,4 +8,48 @@ echo "[ERROR] Some error details" | slackbot
 
 cat file.txt | slackbot
 
-echo "Text message" | slackbot -config ./config.yml`
+echo "Text message" | slackbot -config ./config.yml
+
+echo "Text message" | slackbot -webhook "${SLACK_WEBHOOK}"
+
+echo "Disk is almost full" | slackbot -facts
+
+echo "Replication broken" | slackbot -severity critical
+
+git diff --color | slackbot -diff
+
+echo "Backup 40% done" | slackbot -update-key backup-nightly
+
+echo "Deploy started" | slackbot -ttl 30m
+
+echo "Standup in 5 minutes" | slackbot -at "2026-10-19 09:55"
+
+echo "Check the backup" | slackbot -in 2h
+
+echo "Text message" | slackbot -dry-run -output payload.json
+
+Commands:
+  config show [--origin]   print the merged config and where each value came from
+  config validate          check the config strictly and report problems with line numbers
+  test                     send a labelled test message to every destination
+  systemd-failed <unit>    send the state and journal of a failed systemd unit
+  watch <file>...          follow log files and send lines matching the watch patterns
+  rules test <file|->      show which rules fire for a sample message
+  oncall                   print who is on call now and who is next
+  maintenance start --for 2h [--host glob] [--reason text]
+                           hold messages until the window ends, then send a summary
+  maintenance end [id]     end maintenance windows early
+  maintenance list         show open windows, quiet hours and held messages
+  preview [--color auto|always|never] [--width N]
+                           render message text or a JSON payload from stdin in the terminal
+  serve [--listen host:port]
+                           receive button clicks and slash commands from Slack
+  gc                       remove messages sent with -ttl that have expired
+  schedule list            show messages scheduled with -at or -in
+  schedule cancel <id>     cancel a scheduled message
+  flush                    send the queued messages that are due
+  heartbeat <name> [--expect 1h]
+                           record a ping of a job; serve alerts when pings stop
+  heartbeat <name> --remove
+                           forget a heartbeat
+  heartbeat                list heartbeats and whether they're overdue`
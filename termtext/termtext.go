@@ -0,0 +1,275 @@
+// Package termtext turns captured terminal output into plain text. Progress
+// bars redrawn with carriage returns or cursor movement collapse to their
+// final state, and escape sequences and other control characters are removed.
+package termtext
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options configures Normalize.
+type Options struct {
+	// DiffMarkers prefixes lines printed in red with "- " and lines in
+	// green with "+ ", so that test and diff output keeps its meaning
+	// without colors. Other lines that aren't empty get two spaces.
+	DiffMarkers bool
+}
+
+// Colors tracked for diff markers
+const (
+	colorNone = iota
+	colorRed
+	colorGreen
+)
+
+// maxColumn bounds cursor movement to the right, like the right margin of
+// a terminal, so that a large count can't blow up a line.
+const maxColumn = 4096
+
+// screen is a terminal that never scrolls off: every line written stays.
+type screen struct {
+	lines [][]rune
+	marks []byte // diff marker of each line, 0 for none
+	row   int
+	col   int
+	fg    int
+}
+
+// Normalize interprets s as terminal output and returns the text a
+// terminal would show, without styling.
+func Normalize(s string, o Options) string {
+	sc := &screen{lines: [][]rune{nil}, marks: []byte{0}}
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		switch {
+		case r == '\x1b':
+			i = sc.escape(s, i)
+		case r == '\n':
+			sc.moveTo(sc.row+1, 0)
+		case r == '\r':
+			sc.col = 0
+		case r == '\b':
+			sc.col = max(sc.col-1, 0)
+		case r == '\t':
+			sc.put(r)
+		case r < 0x20 || r == 0x7f || r >= 0x80 && r < 0xa0:
+			// Other C0 and C1 control characters
+		case r == utf8.RuneError && size == 1:
+			sc.put('�')
+		default:
+			sc.put(r)
+		}
+	}
+
+	return sc.String(o.DiffMarkers)
+}
+
+// escape interprets the escape sequence after ESC at s[i:] and returns
+// the index after it. Unterminated sequences swallow the rest of s.
+func (sc *screen) escape(s string, i int) int {
+	if i >= len(s) {
+		return i
+	}
+
+	switch s[i] {
+	case '[':
+		// CSI: parameters, intermediates, final byte
+		j := i + 1
+		for j < len(s) && s[j] >= 0x30 && s[j] <= 0x3f {
+			j++
+		}
+		params := s[i+1 : j]
+		for j < len(s) && s[j] >= 0x20 && s[j] <= 0x2f {
+			j++
+		}
+		if j >= len(s) {
+			return len(s)
+		}
+		sc.csi(params, s[j])
+		return j + 1
+	case ']', 'P', 'X', '^', '_':
+		// OSC, DCS, SOS, PM and APC run until BEL or ST
+		for j := i + 1; j < len(s); j++ {
+			if s[j] == '\x07' {
+				return j + 1
+			}
+			if s[j] == '\x1b' && j+1 < len(s) && s[j+1] == '\\' {
+				return j + 2
+			}
+		}
+		return len(s)
+	default:
+		// Intermediates, then a final byte, such as ESC ( B
+		j := i
+		for j < len(s) && s[j] >= 0x20 && s[j] <= 0x2f {
+			j++
+		}
+		return min(j+1, len(s))
+	}
+}
+
+func (sc *screen) csi(params string, final byte) {
+	args := strings.Split(params, ";")
+	n := func(def int) int {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v <= 0 {
+			return def
+		}
+		return v
+	}
+
+	switch final {
+	case 'm':
+		sc.sgr(args)
+	case 'A', 'F':
+		sc.moveTo(max(sc.row-n(1), 0), sc.col)
+		if final == 'F' {
+			sc.col = 0
+		}
+	case 'B', 'E':
+		// Like at the bottom of a terminal, the cursor stops at the last line
+		sc.moveTo(min(sc.row+n(1), len(sc.lines)-1), sc.col)
+		if final == 'E' {
+			sc.col = 0
+		}
+	case 'C':
+		sc.col = min(sc.col+n(1), maxColumn)
+	case 'D':
+		sc.col = max(sc.col-n(1), 0)
+	case 'G':
+		sc.col = min(n(1), maxColumn) - 1
+	case 'K':
+		sc.eraseLine(n(0))
+	case 'J':
+		// Clearing the whole screen would lose the log, so only the
+		// part below the cursor is erased
+		if n(0) == 0 {
+			sc.eraseLine(0)
+			sc.lines = sc.lines[:sc.row+1]
+			sc.marks = sc.marks[:sc.row+1]
+		}
+	}
+}
+
+func (sc *screen) sgr(args []string) {
+	for i := 0; i < len(args); i++ {
+		switch code, _ := strconv.Atoi(args[i]); {
+		case code == 0, code == 39:
+			sc.fg = colorNone
+		case code == 31, code == 91:
+			sc.fg = colorRed
+		case code == 32, code == 92:
+			sc.fg = colorGreen
+		case code >= 30 && code <= 37, code >= 90 && code <= 97:
+			sc.fg = colorNone
+		case code == 38 && i+2 < len(args) && args[i+1] == "5":
+			switch args[i+2] {
+			case "1", "9", "160", "196":
+				sc.fg = colorRed
+			case "2", "10", "34", "40", "46":
+				sc.fg = colorGreen
+			default:
+				sc.fg = colorNone
+			}
+			i += 2
+		case code == 38 && i+4 < len(args) && args[i+1] == "2":
+			red, _ := strconv.Atoi(args[i+2])
+			green, _ := strconv.Atoi(args[i+3])
+			blue, _ := strconv.Atoi(args[i+4])
+			switch {
+			case red > 150 && green < 100 && blue < 100:
+				sc.fg = colorRed
+			case green > 150 && red < 120 && blue < 150:
+				sc.fg = colorGreen
+			default:
+				sc.fg = colorNone
+			}
+			i += 4
+		}
+	}
+}
+
+func (sc *screen) moveTo(row, col int) {
+	for row >= len(sc.lines) {
+		sc.lines = append(sc.lines, nil)
+		sc.marks = append(sc.marks, 0)
+	}
+	sc.row, sc.col = row, col
+}
+
+// eraseLine erases to the end of the line (0), to its start (1) or all of it (2).
+func (sc *screen) eraseLine(mode int) {
+	line := sc.lines[sc.row]
+	switch mode {
+	case 0:
+		if sc.col < len(line) {
+			sc.lines[sc.row] = line[:sc.col]
+		}
+	case 1:
+		for i := 0; i <= sc.col && i < len(line); i++ {
+			line[i] = ' '
+		}
+	case 2:
+		sc.lines[sc.row] = line[:0]
+	}
+	if len(strings.TrimSpace(string(sc.lines[sc.row]))) == 0 {
+		sc.marks[sc.row] = 0
+	}
+}
+
+func (sc *screen) put(r rune) {
+	line := sc.lines[sc.row]
+	for len(line) < sc.col {
+		line = append(line, ' ')
+	}
+	if sc.col == len(line) {
+		line = append(line, r)
+	} else {
+		line[sc.col] = r
+	}
+	sc.lines[sc.row] = line
+	sc.col++
+
+	if sc.marks[sc.row] == 0 && r != ' ' && r != '\t' {
+		switch sc.fg {
+		case colorRed:
+			sc.marks[sc.row] = '-'
+		case colorGreen:
+			sc.marks[sc.row] = '+'
+		}
+	}
+}
+
+func (sc *screen) String(diff bool) string {
+	if diff {
+		diff = false
+		for _, m := range sc.marks {
+			if m != 0 {
+				diff = true
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, line := range sc.lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if diff && len(line) > 0 {
+			if sc.marks[i] != 0 {
+				b.WriteByte(sc.marks[i])
+				b.WriteByte(' ')
+			} else {
+				b.WriteString("  ")
+			}
+		}
+		b.WriteString(string(line))
+	}
+	return b.String()
+}
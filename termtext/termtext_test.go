@@ -0,0 +1,59 @@
+package termtext
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		desc string
+		in   string
+		want string
+	}{
+		{"plain", "hello\nworld\n", "hello\nworld\n"},
+		{"colors", "\x1b[1;31mFAIL\x1b[0m pkg \x1b[38;5;244m(0.1s)\x1b[m", "FAIL pkg (0.1s)"},
+		{"progress", "  0%\r 50%\r100%\ndone", "100%\ndone"},
+		{"carriage return keeps the rest", "abcdef\rXY", "XYcdef"},
+		{"erase line", "downloading 99%\r\x1b[Kdone", "done"},
+		{"crlf", "one\r\ntwo\r\n", "one\ntwo\n"},
+		{"backspace", "abd\bc", "abc"},
+		{"cursor up", "step 1 ...\nstep 2 ...\n\x1b[2A\x1b[2Kstep 1 ok\n\x1b[2Kstep 2 ok\n", "step 1 ok\nstep 2 ok\n"},
+		{"erase below", "a\nb\nc\x1b[1A\r\x1b[Jx", "a\nx"},
+		{"column", "abcdef\x1b[3GX", "abXdef"},
+		{"hyperlink", "see \x1b]8;;https://example.com\x1b\\docs\x1b]8;;\x1b\\ now", "see docs now"},
+		{"title", "\x1b]0;build\x07ok", "ok"},
+		{"charset", "\x1b(Bok\x1b=", "ok"},
+		{"controls", "a\x00b\x07c\td\x7f", "abc\td"},
+		{"unterminated", "ok\x1b[31", "ok"},
+		{"cursor down stops at the last line", "a\x1b[5Bb\nc\x1b[1A\x1b[300000000Bd", "ab\ncd"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := Normalize(c.in, Options{}); got != c.want {
+				t.Errorf("Normalize(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeBoundsCursor(t *testing.T) {
+	got := Normalize("a\x1b[300000000Cb\x1b[999999999Gc", Options{})
+	if len(got) > maxColumn+2 {
+		t.Fatalf("Normalize() returned %d bytes, want at most %d", len(got), maxColumn+2)
+	}
+	if got[0] != 'a' || got[len(got)-2:] != "cb" {
+		t.Errorf("Normalize() = %q…%q, want a … cb", got[:1], got[len(got)-2:])
+	}
+}
+
+func TestNormalizeDiffMarkers(t *testing.T) {
+	in := "config:\n\x1b[31m  port: 80\x1b[0m\n\x1b[32m  port: 8080\x1b[0m\n\x1b[38;2;40;200;60m  tls: true\x1b[0m\n"
+	want := "  config:\n-   port: 80\n+   port: 8080\n+   tls: true\n"
+	if got := Normalize(in, Options{DiffMarkers: true}); got != want {
+		t.Errorf("Normalize() = %q, want %q", got, want)
+	}
+
+	// Nothing to mark, nothing to prefix
+	if got := Normalize("\x1b[1mplain\x1b[0m\n", Options{DiffMarkers: true}); got != "plain\n" {
+		t.Errorf("Normalize() = %q, want no markers", got)
+	}
+}
@@ -0,0 +1,151 @@
+// Package truncate shortens message text to fit Slack's limits while
+// keeping what matters: the first lines for context and, above all, the
+// last ones, where a failed build prints its errors.
+package truncate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits bounds the size of a text. Zero values mean no limit.
+type Limits struct {
+	MaxChars int // characters, counted as runes
+	MaxLines int
+	Head     int // lines kept from the start
+	Tail     int // lines kept from the end, at least
+}
+
+// Default limits leave room for the code fences around a message in a
+// section block, which Slack caps at 3000 characters.
+var Default = Limits{MaxChars: 2900, Head: 10, Tail: 30}
+
+// escapePattern matches ANSI CSI and OSC sequences and two-byte escapes,
+// which must not be cut in half.
+var escapePattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// Text shortens s to the limits. Whole lines are omitted from the middle
+// and replaced by a line such as "… 1,284 lines omitted …". If the last
+// line alone is still too long, its start is cut instead. The number of
+// omitted lines is returned too.
+func Text(s string, l Limits) (string, int) {
+	if l.MaxChars <= 0 && l.MaxLines <= 0 {
+		return s, 0
+	}
+
+	lines := strings.Split(s, "\n")
+	n := len(lines)
+	if (l.MaxLines <= 0 || n <= l.MaxLines) && (l.MaxChars <= 0 || utf8.RuneCountInString(s) <= l.MaxChars) {
+		return s, 0
+	}
+
+	// Size of each line with its newline
+	sizes := make([]int, n)
+	for i, line := range lines {
+		sizes[i] = utf8.RuneCountInString(line) + 1
+	}
+
+	head := min(max(l.Head, 0), n)
+	tail := n - head
+	if l.MaxLines > 0 && n > l.MaxLines {
+		budget := max(l.MaxLines-1, 1) // one line for the marker
+		tail = min(tail, max(budget-head, min(max(l.Tail, 1), budget)))
+		head = min(head, budget-tail)
+	}
+
+	if l.MaxChars > 0 {
+		// Short texts fit in the head, but the tail keeps priority
+		if want := min(max(l.Tail, 1), n); head+tail == n && tail < want {
+			head, tail = n-want, want
+		}
+
+		size := 0
+		for _, sz := range sizes[:head] {
+			size += sz
+		}
+		for _, sz := range sizes[n-tail:] {
+			size += sz
+		}
+		over := func() bool {
+			total := size
+			if head+tail < n {
+				total += utf8.RuneCountInString(marker(n-head-tail)) + 1
+			}
+			return total-1 > l.MaxChars
+		}
+
+		// Give up the middle first, then the head, then all but the last line
+		for over() && tail > max(l.Tail, 1) {
+			size -= sizes[n-tail]
+			tail--
+		}
+		for over() && head > 0 {
+			head--
+			size -= sizes[head]
+		}
+		for over() && tail > 1 {
+			size -= sizes[n-tail]
+			tail--
+		}
+
+		if over() {
+			room := l.MaxChars
+			if n > 1 {
+				room -= utf8.RuneCountInString(marker(n-1)) + 1
+			}
+			lines[n-1] = keepEnd(lines[n-1], max(room, 1))
+		}
+	}
+
+	omitted := n - head - tail
+	if omitted == 0 {
+		return strings.Join(lines, "\n"), 0
+	}
+
+	kept := make([]string, 0, head+tail+1)
+	kept = append(kept, lines[:head]...)
+	kept = append(kept, marker(omitted))
+	kept = append(kept, lines[n-tail:]...)
+	return strings.Join(kept, "\n"), omitted
+}
+
+func marker(omitted int) string {
+	if omitted == 1 {
+		return "… 1 line omitted …"
+	}
+	return fmt.Sprintf("… %s lines omitted …", thousands(omitted))
+}
+
+// keepEnd returns the last runes of s, starting with "…", so that the
+// result has at most n runes. It never starts inside an escape sequence.
+func keepEnd(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	// Byte offset of the first rune kept
+	cut := len(s)
+	for i := 0; i < n-1 && cut > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(s[:cut])
+		cut -= size
+	}
+
+	for _, span := range escapePattern.FindAllStringIndex(s, -1) {
+		if span[0] < cut && cut < span[1] {
+			cut = span[1]
+		}
+	}
+
+	return "…" + s[cut:]
+}
+
+// thousands formats n with comma separators.
+func thousands(n int) string {
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package truncate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func numbered(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestTextLines(t *testing.T) {
+	got, omitted := Text(numbered(1300), Limits{MaxLines: 10, Head: 3, Tail: 4})
+
+	want := "line 1\nline 2\nline 3\n… 1,291 lines omitted …\n" +
+		"line 1295\nline 1296\nline 1297\nline 1298\nline 1299\nline 1300"
+	if got != want || omitted != 1291 {
+		t.Errorf("Text() = %q, %d\nwant %q, 1291", got, omitted, want)
+	}
+}
+
+func TestTextTailFirst(t *testing.T) {
+	// Too few lines for both: the tail wins
+	got, _ := Text(numbered(100), Limits{MaxLines: 4, Head: 3, Tail: 5})
+	if want := "… 97 lines omitted …\nline 98\nline 99\nline 100"; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestTextChars(t *testing.T) {
+	s := numbered(50)
+	got, omitted := Text(s, Limits{MaxChars: 60, Head: 2, Tail: 3})
+
+	if utf8.RuneCountInString(got) > 60 {
+		t.Errorf("Text() has %d characters, want at most 60", utf8.RuneCountInString(got))
+	}
+	if !strings.HasPrefix(got, "line 1\n") || !strings.HasSuffix(got, "line 48\nline 49\nline 50") {
+		t.Errorf("Text() = %q, want the first and last lines", got)
+	}
+	if omitted == 0 || !strings.Contains(got, fmt.Sprintf("… %d lines omitted …", omitted)) {
+		t.Errorf("Text() = %q, missing the marker for %d lines", got, omitted)
+	}
+
+	if got, omitted := Text("short", Limits{MaxChars: 60, MaxLines: 2}); got != "short" || omitted != 0 {
+		t.Errorf("Text(short) = %q, %d", got, omitted)
+	}
+}
+
+func TestTextLongLine(t *testing.T) {
+	line := strings.Repeat("é", 30) + "\x1b[31mERROR: 日本語 failed\x1b[0m"
+
+	for limit := 5; limit < 60; limit++ {
+		got, _ := Text("build log\n"+line, Limits{MaxChars: limit})
+		if !utf8.ValidString(got) {
+			t.Fatalf("limit %d: invalid UTF-8 in %q", limit, got)
+		}
+		if n := utf8.RuneCountInString(got); n > limit && limit > 30 {
+			t.Errorf("limit %d: %d characters in %q", limit, n, got)
+		}
+		last := got[strings.LastIndex(got, "\n")+1:]
+		if strings.HasPrefix(last, "…[") || strings.Contains(last, "…31m") || strings.Contains(last, "…0m") {
+			t.Errorf("limit %d: cut inside an escape sequence: %q", limit, last)
+		}
+	}
+}
+
+func TestTextShortTail(t *testing.T) {
+	// Fewer lines than the head, but one of them too long
+	got, omitted := Text("\n"+strings.Repeat("x", 5000)+"\nexit status 1", Default)
+	if want := "… 2 lines omitted …\nexit status 1"; got != want || omitted != 2 {
+		t.Errorf("Text() = %.60q, %d, want %q, 2", got, omitted, want)
+	}
+}
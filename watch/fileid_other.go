@@ -0,0 +1,10 @@
+//go:build !unix
+
+package watch
+
+import "os"
+
+// fileID is unknown on this platform; rotation is then detected by size only.
+func fileID(os.FileInfo) string {
+	return ""
+}
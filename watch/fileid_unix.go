@@ -0,0 +1,18 @@
+//go:build unix
+
+package watch
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file across renames, so that a restart can tell
+// whether the saved offset still belongs to the file at the watched path.
+func fileID(fi os.FileInfo) string {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return formatID(uint64(st.Dev), uint64(st.Ino))
+}
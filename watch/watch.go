@@ -0,0 +1,352 @@
+// Package watch follows a log file like `tail -F`, surviving logrotate's
+// rename and truncate, and reports lines that match configured patterns
+// together with the lines around them.
+package watch
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/state"
+)
+
+// Pattern maps a regular expression to the severity and destination of
+// the alert sent when a line matches.
+type Pattern struct {
+	Regex       *regexp.Regexp
+	Severity    severity.Level
+	Destination string // empty for the default destinations
+}
+
+// Match is a group of matching lines with their surrounding context.
+type Match struct {
+	Path        string
+	Lines       []string // context before, the matching lines and context after
+	Matched     int      // number of matching lines in Lines
+	Severity    severity.Level
+	Destination string
+}
+
+// Watcher follows one file.
+type Watcher struct {
+	Path         string
+	Patterns     []Pattern
+	ContextLines int           // lines kept before and after a match
+	PollInterval time.Duration // default 1s
+	Store        *state.Store  // keeps the offset across restarts, may be nil
+	FromStart    bool          // read an unknown file from the beginning instead of the end
+
+	started bool // the saved position was loaded
+	file    *os.File
+	reader  *bufio.Reader
+	partial string // last line, not yet terminated by a newline
+	pos     position
+	before  []string // ring of recent lines for context
+	pending *Match   // match waiting for its trailing context
+	after   int      // trailing context lines still to collect
+
+	// Where the lines in before and the pending match start, so that a
+	// match that wasn't sent yet is read again after a restart
+	beforeAt  []int64
+	pendingAt position
+}
+
+// position is the saved state of a watched file.
+type position struct {
+	FileID string `json:"file_id"`
+	Offset int64  `json:"offset"`
+}
+
+// Run follows the file until ctx is cancelled, calling handle for every
+// match. It returns the first error from handle.
+func (w *Watcher) Run(ctx context.Context, handle func(Match) error) error {
+	if w.PollInterval == 0 {
+		w.PollInterval = time.Second
+	}
+	defer w.close()
+
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(handle); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads everything appended since the last call and checks whether
+// the file was rotated or truncated.
+func (w *Watcher) poll(handle func(Match) error) error {
+	if w.file == nil {
+		if err := w.open(); errors.Is(err, fs.ErrNotExist) {
+			// Between logrotate's rename and the application reopening its
+			// log; whatever file appears next is read from its start
+			w.started = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	opened, err := w.file.Stat()
+	if err != nil {
+		return err
+	}
+	if opened.Size() < w.pos.Offset {
+		// Truncated in place (copytruncate)
+		if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		w.reader.Reset(w.file)
+		w.partial = ""
+		w.pos.Offset = 0
+		w.rewind()
+	}
+
+	read, err := w.readLines(handle)
+	if err != nil {
+		return err
+	}
+
+	current, err := os.Stat(w.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// A missing path means the file was rotated away and not recreated
+	// yet, so keep reading the old one
+	if err == nil && !os.SameFile(opened, current) {
+		// Renamed by logrotate: the old file was drained above,
+		// continue with the new one from its beginning
+		if w.partial != "" {
+			if err := w.process(trimNewline(w.partial), w.pos.Offset, handle); err != nil {
+				return err
+			}
+		}
+		w.close()
+		w.pos = position{}
+		w.rewind()
+		if err := w.open(); err != nil {
+			return err
+		}
+		n, err := w.readLines(handle)
+		if err != nil {
+			return err
+		}
+		read += n
+	}
+
+	// Nothing more arrived during this poll, so don't hold back the match
+	if read == 0 && w.pending != nil {
+		if err := w.flush(handle); err != nil {
+			return err
+		}
+	}
+
+	return w.save()
+}
+
+// open opens the file at the saved offset if it is the same file, at the
+// end for a file seen for the first time, or at the start otherwise.
+func (w *Watcher) open() error {
+	f, err := os.Open(w.Path)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	id := fileID(fi)
+	if !w.started {
+		w.started = true
+		if w.Store != nil {
+			if err := w.Store.Load(w.stateName(), &w.pos); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if w.pos == (position{}) && !w.FromStart {
+			// Don't alert on history when a file is watched for the first time
+			w.pos = position{FileID: id, Offset: fi.Size()}
+		}
+	}
+
+	if w.pos.FileID != "" && w.pos.FileID != id || w.pos.Offset > fi.Size() {
+		// Rotated or truncated while we weren't watching
+		w.pos.Offset = 0
+	}
+	w.pos.FileID = id
+
+	if _, err := f.Seek(w.pos.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	w.file = f
+	w.reader = bufio.NewReader(f)
+	w.partial = ""
+	return nil
+}
+
+func (w *Watcher) close() {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+}
+
+// readLines consumes complete lines up to the end of the file. Lines of
+// any length are supported; a final line without a newline is kept until
+// it is completed.
+func (w *Watcher) readLines(handle func(Match) error) (int, error) {
+	n := 0
+	for {
+		chunk, err := w.reader.ReadString('\n')
+		if err == io.EOF {
+			w.partial += chunk
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		line := w.partial + chunk
+		w.partial = ""
+		at := w.pos.Offset
+		w.pos.Offset += int64(len(line))
+		n++
+
+		if err := w.process(trimNewline(line), at, handle); err != nil {
+			return n, err
+		}
+	}
+}
+
+// process checks one line, starting at offset at, against the patterns and
+// maintains the context.
+func (w *Watcher) process(line string, at int64, handle func(Match) error) error {
+	if p, ok := w.match(line); ok {
+		if w.pending == nil {
+			w.pending = &Match{
+				Path:        w.Path,
+				Lines:       append([]string{}, w.before...),
+				Severity:    p.Severity,
+				Destination: p.Destination,
+			}
+			w.pendingAt = position{FileID: w.pos.FileID, Offset: at}
+			if len(w.beforeAt) > 0 {
+				w.pendingAt.Offset = w.beforeAt[0]
+			}
+		} else if p.Severity > w.pending.Severity {
+			w.pending.Severity = p.Severity
+			w.pending.Destination = p.Destination
+		}
+		w.pending.Lines = append(w.pending.Lines, line)
+		w.pending.Matched++
+		w.after = w.ContextLines
+		w.before = w.before[:0]
+		w.beforeAt = w.beforeAt[:0]
+		if w.after == 0 {
+			return w.flush(handle)
+		}
+		return nil
+	}
+
+	if w.pending != nil {
+		w.pending.Lines = append(w.pending.Lines, line)
+		if w.after--; w.after <= 0 {
+			return w.flush(handle)
+		}
+		return nil
+	}
+
+	if w.ContextLines > 0 {
+		if len(w.before) == w.ContextLines {
+			w.before = append(w.before[:0], w.before[1:]...)
+			w.beforeAt = append(w.beforeAt[:0], w.beforeAt[1:]...)
+		}
+		w.before = append(w.before, line)
+		w.beforeAt = append(w.beforeAt, at)
+	}
+	return nil
+}
+
+// rewind forgets where the lines read so far start once the file is read
+// from its beginning again: a match that spans a rotation or truncation
+// can't be read again.
+func (w *Watcher) rewind() {
+	for i := range w.beforeAt {
+		w.beforeAt[i] = 0
+	}
+	w.pendingAt = position{}
+}
+
+// match returns the first pattern that matches line.
+func (w *Watcher) match(line string) (Pattern, bool) {
+	for _, p := range w.Patterns {
+		if p.Regex.MatchString(line) {
+			return p, true
+		}
+	}
+	return Pattern{}, false
+}
+
+func (w *Watcher) flush(handle func(Match) error) error {
+	m := *w.pending
+	w.pending = nil
+	w.after = 0
+	return handle(m)
+}
+
+// save persists the offset so that a restart neither repeats nor skips
+// lines. While a match waits for its trailing context, the offset is kept
+// at its start, so that it's sent after a restart rather than lost.
+func (w *Watcher) save() error {
+	if w.Store == nil || w.file == nil {
+		return nil
+	}
+	pos := w.pos
+	if w.pending != nil && w.pendingAt.FileID == pos.FileID {
+		pos = w.pendingAt
+	}
+	return w.Store.Save(w.stateName(), pos)
+}
+
+// stateName derives a state document name from the watched path.
+func (w *Watcher) stateName() string {
+	sum := sha256.Sum256([]byte(w.Path))
+	return "watch-" + hex.EncodeToString(sum[:8])
+}
+
+func trimNewline(s string) string {
+	if len(s) > 0 && s[len(s)-1] == '\n' {
+		s = s[:len(s)-1]
+	}
+	if len(s) > 0 && s[len(s)-1] == '\r' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func formatID(dev, ino uint64) string {
+	return fmt.Sprintf("%d:%d", dev, ino)
+}
@@ -0,0 +1,176 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/maxkulish/slackbot/severity"
+	"github.com/maxkulish/slackbot/state"
+)
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, l := range lines {
+		if _, err := f.WriteString(l + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// collector records matches and lets tests poll the watcher step by step.
+type collector struct {
+	t       *testing.T
+	w       *Watcher
+	matches []Match
+}
+
+func newCollector(t *testing.T, path string, store *state.Store) *collector {
+	return &collector{t: t, w: &Watcher{
+		Path: path,
+		Patterns: []Pattern{
+			{Regex: regexp.MustCompile(`FATAL`), Severity: severity.Critical, Destination: "oncall"},
+			{Regex: regexp.MustCompile(`ERROR`), Severity: severity.Error},
+		},
+		ContextLines: 1,
+		Store:        store,
+	}}
+}
+
+func (c *collector) poll() []string {
+	c.t.Helper()
+
+	c.matches = nil
+	err := c.w.poll(func(m Match) error {
+		c.matches = append(c.matches, m)
+		return nil
+	})
+	if err != nil {
+		c.t.Fatalf("poll() error = %v", err)
+	}
+
+	var got []string
+	for _, m := range c.matches {
+		got = append(got, strings.Join(m.Lines, "|"))
+	}
+	return got
+}
+
+func expect(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("matches = %q, want %q", got, want)
+	}
+}
+
+func TestWatchContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLines(t, path, "old ERROR, before watching")
+
+	c := newCollector(t, path, nil)
+	expect(t, c.poll())
+
+	appendLines(t, path, "starting", "ready", "ERROR one", "FATAL two", "after")
+	expect(t, c.poll(), "ready|ERROR one|FATAL two|after")
+
+	m := c.matches[0]
+	if m.Matched != 2 || m.Severity != severity.Critical || m.Destination != "oncall" {
+		t.Errorf("match = %+v, want 2 lines at critical severity for oncall", m)
+	}
+
+	// A match at the end of the data is sent without waiting for more lines
+	appendLines(t, path, "ERROR last")
+	expect(t, c.poll())
+	expect(t, c.poll(), "ERROR last")
+}
+
+func TestWatchRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "start")
+
+	c := newCollector(t, path, nil)
+	c.w.ContextLines = 0
+	c.poll()
+
+	// logrotate's default: rename, then the application creates a new file
+	appendLines(t, path, "ERROR before rotation")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, c.poll(), "ERROR before rotation")
+
+	appendLines(t, path, "ERROR in new file")
+	expect(t, c.poll(), "ERROR in new file")
+
+	// copytruncate: the same file is truncated in place
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, c.poll())
+	appendLines(t, path, "ERROR after truncate")
+	expect(t, c.poll(), "ERROR after truncate")
+}
+
+func TestWatchResumesAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	store := &state.Store{Dir: t.TempDir()}
+	appendLines(t, path, "start")
+
+	first := newCollector(t, path, store)
+	first.w.ContextLines = 0
+	first.poll()
+	appendLines(t, path, "ERROR seen by the first watcher")
+	expect(t, first.poll(), "ERROR seen by the first watcher")
+	first.w.close()
+
+	// Written while slackbot wasn't running
+	appendLines(t, path, "ERROR while stopped")
+
+	second := newCollector(t, path, store)
+	second.w.ContextLines = 0
+	expect(t, second.poll(), "ERROR while stopped")
+}
+
+func TestWatchKeepsPendingMatchAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	store := &state.Store{Dir: t.TempDir()}
+	appendLines(t, path, "start")
+
+	first := newCollector(t, path, store)
+	first.poll()
+	// Stopped while the match waits for its trailing context
+	appendLines(t, path, "ready", "ERROR unsent")
+	expect(t, first.poll())
+	first.w.close()
+
+	second := newCollector(t, path, store)
+	expect(t, second.poll())
+	appendLines(t, path, "after")
+	expect(t, second.poll(), "ready|ERROR unsent|after")
+}
+
+func TestWatchLongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLines(t, path, "start")
+
+	c := newCollector(t, path, nil)
+	c.w.ContextLines = 0
+	c.poll()
+
+	long := strings.Repeat("x", 1<<20) + " ERROR"
+	appendLines(t, path, long)
+	got := c.poll()
+	if len(got) != 1 || got[0] != long {
+		t.Errorf("a 1 MiB line should match as a whole, got %d matches", len(got))
+	}
+}